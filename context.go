@@ -0,0 +1,75 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinko
+
+import "context"
+
+type canFireKey struct{}
+type triggerQueueKey struct{}
+type triggerArgsKey struct{}
+type partialPayloadKey struct{}
+
+// WithCanFire marks the context as belonging to a CanFire evaluation.
+func WithCanFire(ctx context.Context) context.Context {
+	return context.WithValue(ctx, canFireKey{}, true)
+}
+
+// IsCanFire reports if the middleware chain is evaluating CanFire rather than Fire, allowing
+// middleware to skip work such as locking that only matters when a transition is executed.
+func IsCanFire(ctx context.Context) bool {
+	v, _ := ctx.Value(canFireKey{}).(bool)
+	return v
+}
+
+// WithTriggerQueue attaches the queue that operations running under the context enqueue
+// follow-up triggers on.
+func WithTriggerQueue(ctx context.Context, queue TriggerQueue) context.Context {
+	return context.WithValue(ctx, triggerQueueKey{}, queue)
+}
+
+// TriggerQueueFrom returns the queue of the transition in progress, if any.
+func TriggerQueueFrom(ctx context.Context) (TriggerQueue, bool) {
+	q, ok := ctx.Value(triggerQueueKey{}).(TriggerQueue)
+	return q, ok
+}
+
+// EnqueueTrigger queues a trigger to be fired once the transition in progress completes.
+// It returns false when the context does not belong to a transition.
+func EnqueueTrigger(ctx context.Context, trigger Trigger) bool {
+	q, ok := TriggerQueueFrom(ctx)
+	if !ok {
+		return false
+	}
+
+	q.Enqueue(trigger)
+	return true
+}
+
+// WithTriggerArgs attaches the arguments sent along with a trigger, for the operations of the
+// transition to read.
+func WithTriggerArgs(ctx context.Context, args ...interface{}) context.Context {
+	return context.WithValue(ctx, triggerArgsKey{}, args)
+}
+
+// TriggerArgs returns the arguments sent along with the trigger being fired, if any.
+func TriggerArgs(ctx context.Context) []interface{} {
+	args, _ := ctx.Value(triggerArgsKey{}).([]interface{})
+	return args
+}
+
+// WithPartialPayload attaches the payload as a failed transition left it, once the payload has
+// been rolled back.
+func WithPartialPayload(ctx context.Context, payload Payload) context.Context {
+	return context.WithValue(ctx, partialPayloadKey{}, payload)
+}
+
+// PartialPayload returns, to the error operations of a transition that was rolled back, the
+// payload as the failed operations left it.
+func PartialPayload(ctx context.Context) (Payload, bool) {
+	p, ok := ctx.Value(partialPayloadKey{}).(Payload)
+	return p, ok
+}
@@ -2,6 +2,7 @@ package plinko
 
 import (
 	"context"
+	"time"
 )
 
 type State string
@@ -12,23 +13,53 @@ type TriggerPredicate func(context.Context, Payload, TransitionInfo) bool
 type Operation func(context.Context, Payload, TransitionInfo) (Payload, error)
 type ErrorOperation func(context.Context, Payload, ModifiableTransitionInfo, error) (Payload, error)
 
+// Guard is a predicate that describes itself, such as those built by the guard package.  The
+// description labels the transitions the guard is permitted with.
+type Guard interface {
+	Check(context.Context, Payload, TransitionInfo) error
+	String() string
+}
+
 type StateDefinition interface {
 	//State() string
+	Defer(Trigger) StateDefinition
 	OnEntry(Operation, ...OperationOption) StateDefinition
+	OnEntryWhen(TriggerPredicate, Operation, ...OperationOption) StateDefinition
+	OnEntryFrom(State, Operation, ...OperationOption) StateDefinition
+	OnEntryIf(Predicate, Operation, ...OperationOption) StateDefinition
 	OnError(ErrorOperation, ...OperationOption) StateDefinition
 	OnExit(Operation, ...OperationOption) StateDefinition
+	OnExitWhen(TriggerPredicate, Operation, ...OperationOption) StateDefinition
+	OnExitTo(State, Operation, ...OperationOption) StateDefinition
 	OnTriggerEntry(Trigger, Operation, ...OperationOption) StateDefinition
 	OnTriggerExit(Trigger, Operation, ...OperationOption) StateDefinition
-	Permit(Trigger, State) StateDefinition
-	PermitIf(Predicate, Trigger, State) StateDefinition
-	PermitReentry(Trigger) StateDefinition
-	PermitReentryIf(Predicate, Trigger) StateDefinition
+	Permit(Trigger, State, ...TriggerOption) StateDefinition
+	PermitIf(Predicate, Trigger, State, ...TriggerOption) StateDefinition
+	PermitWhen(TriggerPredicate, Trigger, State, ...TriggerOption) StateDefinition
+	PermitGuard(Guard, Trigger, State, ...TriggerOption) StateDefinition
+	PermitReentry(Trigger, ...TriggerOption) StateDefinition
+	PermitReentryIf(Predicate, Trigger, ...TriggerOption) StateDefinition
+	PermitReentryWhen(TriggerPredicate, Trigger, ...TriggerOption) StateDefinition
+	PermitReentryGuard(Guard, Trigger, ...TriggerOption) StateDefinition
+	PermitToHistory(Trigger, State, ...TriggerOption) StateDefinition
+	PermitToDeepHistory(Trigger, State, ...TriggerOption) StateDefinition
+	PermitJoin(Trigger, State, ...TriggerOption) StateDefinition
 }
 
 type StateMachine interface {
 	Fire(context.Context, Payload, Trigger) (Payload, error)
+	FireWithResults(context.Context, Payload, Trigger) (Payload, []TransitionResult, error)
+	FireByID(context.Context, string, Trigger) (Payload, error)
+	FireIdempotent(context.Context, Payload, Trigger, string) (Payload, error)
+	FireBatch(context.Context, []Payload, Trigger, ...BatchOption) (BatchResult, error)
 	CanFire(context.Context, Payload, Trigger) error
 	EnumerateActiveTriggers(payload Payload) ([]Trigger, error)
+	Validate(context.Context, Payload) error
+	StatesWithTag(string) []State
+	StateInfo(State) (StateConfig, bool)
+	HasTag(Payload, string) bool
+	Operations(State) (OperationChains, bool)
+	Describe() Description
 }
 
 type TransitionInfo interface {
@@ -37,6 +68,21 @@ type TransitionInfo interface {
 	GetTrigger() Trigger
 }
 
+// TransitionResult reports a transition executed during a call to Fire, including the
+// follow-up transitions raised through the TriggerQueue.
+type TransitionResult struct {
+	Source      State
+	Destination State
+	Trigger     Trigger
+	Err         error
+}
+
+// TriggerQueue accepts triggers raised while a transition is in progress.  Queued triggers
+// are fired in order once the current transition has completed, including its side effects.
+type TriggerQueue interface {
+	Enqueue(Trigger)
+}
+
 type ModifiableTransitionInfo interface {
 	GetSource() State
 	GetDestination() State
@@ -46,10 +92,21 @@ type ModifiableTransitionInfo interface {
 
 type SideEffect func(context.Context, StateAction, Payload, TransitionInfo, int64)
 
+// FireFunc describes a trigger being fired at a payload, the unit of work wrapped by Middleware.
+type FireFunc func(context.Context, Payload, Trigger) (Payload, error)
+
+// Middleware wraps every Fire and CanFire call.  A middleware may inspect or short-circuit
+// the trigger, replace the context, and post-process the results of the next FireFunc.
+type Middleware func(next FireFunc) FireFunc
+
 type PlinkoDefinition interface {
 	Configure(State, ...StateOption) StateDefinition
-	SideEffect(SideEffect) PlinkoDefinition
-	FilteredSideEffect(SideEffectFilter, SideEffect) PlinkoDefinition
+	SideEffect(SideEffect, ...SideEffectOption) PlinkoDefinition
+	FilteredSideEffect(SideEffectFilter, SideEffect, ...SideEffectOption) PlinkoDefinition
+	Use(...Middleware) PlinkoDefinition
+	OnAnyEntry(Operation, ...OperationOption) PlinkoDefinition
+	OnAnyExit(Operation, ...OperationOption) PlinkoDefinition
+	OnAnyError(ErrorOperation, ...OperationOption) PlinkoDefinition
 	Compile() CompilerOutput
 	RenderUml() (Uml, error)
 	Render(Renderer) error
@@ -60,7 +117,7 @@ type Renderer interface {
 }
 
 type Graph interface {
-	Edges(func(State, State, Trigger))
+	Edges(func(State, State, Trigger, TriggerConfig))
 	Nodes(func(State, StateConfig))
 }
 
@@ -68,6 +125,134 @@ type Payload interface {
 	GetState() State
 }
 
+// Store loads and saves payloads by their identifier.  Every save increments the version of the
+// payload; a save is rejected with a plinkoerror.PlinkoConflictError when the stored version no
+// longer matches the version the caller loaded, guarding against concurrent updates.  A payload
+// that has never been saved has version 0.
+type Store interface {
+	Load(ctx context.Context, id string) (Payload, int64, error)
+	Save(ctx context.Context, id string, payload Payload, expectedVersion int64) (int64, error)
+}
+
+// TransactionalStore is implemented by stores that can run the load, fire and save of FireByID
+// within a single transaction.  The context passed to fn carries the transaction, so the
+// operations run by the transition can take part in it.
+type TransactionalStore interface {
+	Store
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TransitionRecorder is implemented by stores that keep a history of the transitions FireByID
+// made, recorded after the payload is saved with the version the save produced.  When the fire
+// fails, its transitions are recorded outside the transaction that was rolled back, with the
+// version the payload remains at and the error of the transition that failed.
+type TransitionRecorder interface {
+	RecordTransitions(ctx context.Context, id string, version int64, results []TransitionResult) error
+}
+
+// Identifiable is implemented by payloads that carry the identifier of the entity they
+// represent.
+type Identifiable interface {
+	GetID() string
+}
+
+// StatefulPayload is implemented by payloads that can record their own state.  Fire calls
+// SetState with the destination once the exit operations of a transition have run and before
+// the entry operations run, so definitions need no entry operation to assign the state.
+type StatefulPayload interface {
+	Payload
+	SetState(State) Payload
+}
+
+// Cloneable is implemented by payloads that can be copied, so a transition that fails can
+// roll back the changes its operations made to the payload.  Clone must return a copy that
+// shares no mutable state with the original.
+type Cloneable interface {
+	Clone() Payload
+}
+
+// Restorable is implemented by cloneable payloads that can be reset in place, so rolling back
+// a failed transition also restores the payload the caller of Fire holds.  Restore must
+// overwrite the receiver with the contents of the copy it is given.
+type Restorable interface {
+	Cloneable
+	Restore(from Payload)
+}
+
+// Locker serializes transitions on the same entity.  Lock blocks until the lock for the id is
+// held or the context is done, and returns the function that releases it.
+type Locker interface {
+	Lock(ctx context.Context, id string) (unlock func(), err error)
+}
+
+// LockKeyer is implemented by lockers that serialize several ids under the same lock, such as
+// a striped lock.  LockKey returns the key of the lock the id is held under, so a Fire nested
+// within an operation does not wait on a lock its caller already holds for a different id.
+type LockKeyer interface {
+	LockKey(id string) string
+}
+
+// JournalEntry records a transition completed by Fire.  Operations lists the names of the
+// exit and entry operations that ran, in order.
+type JournalEntry struct {
+	ID          string        `json:"id,omitempty"`
+	Source      State         `json:"source"`
+	Destination State         `json:"destination"`
+	Trigger     Trigger       `json:"trigger"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Elapsed     time.Duration `json:"elapsed"`
+	Operations  []string      `json:"operations,omitempty"`
+}
+
+// Journal records every transition completed by Fire, for auditing how an entity reached its
+// current state.  The ID of an entry is set when the payload implements Identifiable.
+type Journal interface {
+	Record(ctx context.Context, payload Payload, entry JournalEntry) error
+}
+
+// IdempotentResult is the outcome of the first call to FireIdempotent for a key.
+type IdempotentResult struct {
+	Payload Payload
+	Err     error
+}
+
+// IdempotencyStore remembers the outcome of FireIdempotent by idempotency key, so a repeated
+// key returns that outcome instead of firing the trigger again.  Reserve returns the outcome
+// recorded for the key with done set; otherwise it claims the key for the caller, who resolves
+// it by recording the outcome with Put, or by giving up the claim with Release when the outcome
+// should not be remembered.  A call reserving a key claimed by another waits until the key is
+// resolved or released, or the context is done.
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, key string) (result IdempotentResult, done bool, err error)
+	Put(ctx context.Context, key string, result IdempotentResult) error
+	Release(ctx context.Context, key string) error
+}
+
+// HistoryStore records the last active substate of a composite state for a payload, so a
+// history transition can resume where the payload left off.  It is implemented by the
+// payload itself or by a store configured on the definition.
+type HistoryStore interface {
+	GetHistory(ctx context.Context, payload Payload, composite State) (State, bool, error)
+	SetHistory(ctx context.Context, payload Payload, composite State, state State) error
+}
+
+// RegionalPayload is implemented by payloads that enter parallel states.  While the payload is
+// in a parallel state, it carries the active state of each of its orthogonal regions, keyed by
+// the region state.
+type RegionalPayload interface {
+	Payload
+	GetRegionStates() map[State]State
+	SetRegionState(region State, state State)
+}
+
+// DeferredTriggerStore is implemented by payloads that can hold triggers deferred by their
+// current state.  A deferred trigger is fired again once the payload enters a state that
+// permits it.
+type DeferredTriggerStore interface {
+	GetDeferredTriggers() []Trigger
+	SetDeferredTriggers([]Trigger)
+}
+
 type CompilerMessage struct {
 	CompileMessage CompilerReportType
 	Message        string
@@ -87,6 +272,12 @@ const (
 	BeforeTransition StateAction = "BeforeTransition"
 	BetweenStates    StateAction = "MiddleTransition"
 	AfterTransition  StateAction = "AfterTransition"
+	// RetryableFailure is signaled when a transition fails with a retryable error, leaving the
+	// payload in its source state.
+	RetryableFailure StateAction = "RetryableFailure"
+	// FatalFailure is signaled when a transition fails with a fatal error and the payload has
+	// been routed to the failure state of the definition.
+	FatalFailure StateAction = "FatalFailure"
 )
 
 type SideEffectFilter int
@@ -95,6 +286,8 @@ const (
 	AllowBeforeTransition SideEffectFilter = 1
 	AllowBetweenStates    SideEffectFilter = 2
 	AllowAfterTransition  SideEffectFilter = 4
+	AllowRetryableFailure SideEffectFilter = 8
+	AllowFatalFailure     SideEffectFilter = 16
 )
 
 type Uml string
@@ -105,14 +298,167 @@ type CompilerOutput struct {
 }
 
 type OperationConfig struct {
-	Name string
+	Name        string
+	Description string
+	Tags        []string
+	// Priority orders operations that are not constrained by Before and After, highest first.
+	Priority int
+	// Before and After name the operations of the same chain this operation runs before or after.
+	Before []string
+	After  []string
+}
+
+// OperationChains lists the operations of a state in the order they run, once the ordering
+// constraints of the operations have been resolved.
+type OperationChains struct {
+	Entry []OperationConfig
+	Exit  []OperationConfig
+	Error []OperationConfig
 }
 
 type OperationOption func(c *OperationConfig)
 
+// Description is a read-only model of a compiled definition, returned by StateMachine.Describe.
+// States are listed in the order they were configured.
+type Description struct {
+	States      []StateDescription
+	Hooks       OperationChains
+	SideEffects []SideEffectDescription
+}
+
+// StateDescription describes a state, the triggers it permits and defers, sorted by name, and
+// its operations in the order they run.
+type StateDescription struct {
+	State      State
+	Config     StateConfig
+	Triggers   []TriggerDescription
+	Deferred   []Trigger
+	Operations OperationChains
+}
+
+// TriggerDescription describes a transition permitted by a state.  Guard names the guard of the
+// transition, and is empty when the transition has none.
+type TriggerDescription struct {
+	Trigger     Trigger
+	Destination State
+	Guard       string
+	Config      TriggerConfig
+}
+
+// SideEffectDescription describes a registered side effect, named after its function.
+type SideEffectDescription struct {
+	Name   string
+	Filter SideEffectFilter
+	Config SideEffectConfig
+}
+
+// Invariant is a named rule a payload must satisfy while it is in a state.
+type Invariant struct {
+	Name  string
+	Check func(context.Context, Payload) error
+}
+
 type StateConfig struct {
 	Name        string
 	Description string
+	Parent      State
+	Parallel    bool
+	Initial     bool
+	Final       bool
+	Invariants  []Invariant
+	Tags        []string
+	Metadata    map[string]interface{}
 }
 
 type StateOption func(c *StateConfig)
+
+// DefaultMaxTriggerQueueDepth is the number of queued follow-up triggers a single Fire
+// call processes before giving up, guarding against transitions that trigger each other
+// indefinitely.
+const DefaultMaxTriggerQueueDepth = 16
+
+type DefinitionConfig struct {
+	MaxTriggerQueueDepth int
+	HistoryStore         HistoryStore
+	Store                Store
+	Journal              Journal
+	IdempotencyStore     IdempotencyStore
+	Locker               Locker
+	LockTimeout          time.Duration
+	RollbackOnError      bool
+	GlobalHookOrder      HookOrder
+	FailureState         State
+}
+
+type DefinitionOption func(c *DefinitionConfig)
+
+// HookOrder places the operations registered with OnAnyEntry, OnAnyExit and OnAnyError relative
+// to the operations of the states taking part in a transition.
+type HookOrder int
+
+const (
+	// GlobalHooksBefore runs the global operations before the operations of the states.
+	GlobalHooksBefore HookOrder = iota
+	// GlobalHooksAfter runs the global operations after the operations of the states.
+	GlobalHooksAfter
+)
+
+type HistoryType int
+
+const (
+	NoHistory HistoryType = iota
+	// ShallowHistory resumes the direct substate of the composite that was last active.
+	ShallowHistory
+	// DeepHistory resumes the innermost state that was last active within the composite.
+	DeepHistory
+)
+
+type TriggerConfig struct {
+	History HistoryType
+	Join    bool
+	// Guard describes the guard of the transition when it was permitted with a plinko.Guard or a
+	// boolean guard.
+	Guard       string
+	Description string
+	Tags        []string
+	Metadata    map[string]interface{}
+}
+
+type TriggerOption func(c *TriggerConfig)
+
+// SideEffectConfig narrows the transitions a side effect is signaled for.  An empty
+// list places no restriction on that part of the transition.
+type SideEffectConfig struct {
+	Sources      []State
+	Destinations []State
+	Triggers     []Trigger
+}
+
+type SideEffectOption func(c *SideEffectConfig)
+
+// DefaultBatchConcurrency is the number of payloads FireBatch fires at once when no
+// concurrency is configured.
+const DefaultBatchConcurrency = 8
+
+type BatchConfig struct {
+	Concurrency      int
+	SkipIfCannotFire bool
+}
+
+type BatchOption func(c *BatchConfig)
+
+// BatchItemResult is the outcome of firing the trigger on one payload of a batch.  A skipped
+// payload carries the error CanFire reported for it.
+type BatchItemResult struct {
+	Payload Payload
+	Err     error
+	Skipped bool
+}
+
+// BatchResult reports the outcome of FireBatch.  Items are in the order of the payloads given.
+type BatchResult struct {
+	Items     []BatchItemResult
+	Succeeded int
+	Failed    int
+	Skipped   int
+}
@@ -68,6 +68,25 @@ func (cd *CallbackDefinitions) AddExit(predicate plinko.Predicate, operation pli
 	return cd
 }
 
+type operationTraceKey struct{}
+
+// OperationTrace collects the names of the entry and exit operations executed during a
+// transition, in the order they ran.
+type OperationTrace struct {
+	Names []string
+}
+
+// WithOperationTrace returns a context under which executed operations are added to the trace.
+func WithOperationTrace(ctx context.Context, trace *OperationTrace) context.Context {
+	return context.WithValue(ctx, operationTraceKey{}, trace)
+}
+
+func traceOperation(ctx context.Context, name string) {
+	if trace, ok := ctx.Value(operationTraceKey{}).(*OperationTrace); ok {
+		trace.Names = append(trace.Names, name)
+	}
+}
+
 func executeChain(ctx context.Context, funcs []ChainedFunctionCall, p plinko.Payload, t plinko.TransitionInfo) (retPayload plinko.Payload, err error) {
 	var stepName string
 	step := 0
@@ -91,6 +110,7 @@ func executeChain(ctx context.Context, funcs []ChainedFunctionCall, p plinko.Pay
 			var e error
 			p, e = fn.Operation(ctx, p, t)
 			step++
+			traceOperation(ctx, stepName)
 			if e != nil {
 				return p, e
 			}
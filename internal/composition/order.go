@@ -0,0 +1,235 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package composition
+
+import (
+	"sort"
+
+	"github.com/shipt/plinko"
+)
+
+// OrderingIssue reports a chain whose Before and After constraints could not be honoured.  It
+// either lists the operations ordered in a cycle, or names an operation ordered relative to a
+// reference that is not part of the chain.
+type OrderingIssue struct {
+	Chain     string
+	Cycle     []string
+	Operation string
+	Reference string
+}
+
+// Resolve returns a copy of the definitions with each chain ordered by the Before and After
+// constraints of its operations, then by priority, highest first, and then by declaration.
+func (cd *CallbackDefinitions) Resolve() (*CallbackDefinitions, []OrderingIssue) {
+	var issues []OrderingIssue
+
+	entryOrder, entryIssues := orderChain("entry", functionConfigs(cd.OnEntryFn))
+	exitOrder, exitIssues := orderChain("exit", functionConfigs(cd.OnExitFn))
+	errorOrder, errorIssues := orderChain("error", errorConfigs(cd.OnErrorFn))
+
+	issues = append(issues, entryIssues...)
+	issues = append(issues, exitIssues...)
+	issues = append(issues, errorIssues...)
+
+	resolved := CallbackDefinitions{}
+
+	for _, i := range entryOrder {
+		resolved.OnEntryFn = append(resolved.OnEntryFn, cd.OnEntryFn[i])
+		resolved.EntryFunctionChain = append(resolved.EntryFunctionChain, cd.OnEntryFn[i].Config.Name)
+	}
+
+	for _, i := range exitOrder {
+		resolved.OnExitFn = append(resolved.OnExitFn, cd.OnExitFn[i])
+		resolved.ExitFunctionChain = append(resolved.ExitFunctionChain, cd.OnExitFn[i].Config.Name)
+	}
+
+	for _, i := range errorOrder {
+		resolved.OnErrorFn = append(resolved.OnErrorFn, cd.OnErrorFn[i])
+	}
+
+	return &resolved, issues
+}
+
+func functionConfigs(calls []ChainedFunctionCall) []plinko.OperationConfig {
+	configs := make([]plinko.OperationConfig, len(calls))
+	for i, call := range calls {
+		configs[i] = call.Config
+	}
+
+	return configs
+}
+
+func errorConfigs(calls []ChainedErrorCall) []plinko.OperationConfig {
+	configs := make([]plinko.OperationConfig, len(calls))
+	for i, call := range calls {
+		configs[i] = call.Config
+	}
+
+	return configs
+}
+
+// orderChain returns the indexes of the operations in the order they run.  Operations that are
+// ordered in a cycle, or after one, keep their declaration order at the end of the chain.
+func orderChain(chain string, configs []plinko.OperationConfig) ([]int, []OrderingIssue) {
+	var issues []OrderingIssue
+
+	successors := make([][]int, len(configs))
+	predecessors := make([]int, len(configs))
+
+	link := func(before, after int) {
+		successors[before] = append(successors[before], after)
+		predecessors[after]++
+	}
+
+	for i, c := range configs {
+		for _, name := range c.Before {
+			found := false
+			for j := range configs {
+				if j != i && configs[j].Name == name {
+					link(i, j)
+					found = true
+				}
+			}
+
+			if !found {
+				issues = append(issues, OrderingIssue{Chain: chain, Operation: c.Name, Reference: name})
+			}
+		}
+
+		for _, name := range c.After {
+			found := false
+			for j := range configs {
+				if j != i && configs[j].Name == name {
+					link(j, i)
+					found = true
+				}
+			}
+
+			if !found {
+				issues = append(issues, OrderingIssue{Chain: chain, Operation: c.Name, Reference: name})
+			}
+		}
+	}
+
+	order := make([]int, 0, len(configs))
+	done := make([]bool, len(configs))
+
+	for len(order) < len(configs) {
+		next := -1
+		for i, c := range configs {
+			if done[i] || predecessors[i] > 0 {
+				continue
+			}
+
+			if next == -1 || c.Priority > configs[next].Priority {
+				next = i
+			}
+		}
+
+		if next == -1 {
+			break
+		}
+
+		done[next] = true
+		order = append(order, next)
+
+		for _, j := range successors[next] {
+			predecessors[j]--
+		}
+	}
+
+	if len(order) < len(configs) {
+		for _, component := range cycles(successors, done) {
+			cycle := make([]string, len(component))
+			for k, i := range component {
+				cycle[k] = configs[i].Name
+			}
+
+			issues = append(issues, OrderingIssue{Chain: chain, Cycle: cycle})
+		}
+
+		for i := range configs {
+			if !done[i] {
+				order = append(order, i)
+			}
+		}
+	}
+
+	return order, issues
+}
+
+// cycles returns the strongly connected components of more than one operation among those
+// that could not be placed, so operations that only follow a cycle are not reported as part
+// of it.  Components and their operations are in declaration order.
+func cycles(successors [][]int, placed []bool) [][]int {
+	index := make([]int, len(successors))
+	low := make([]int, len(successors))
+	onStack := make([]bool, len(successors))
+	for i := range index {
+		index[i] = -1
+	}
+
+	var stack []int
+	var components [][]int
+	next := 0
+
+	var visit func(i int)
+	visit = func(i int) {
+		index[i], low[i] = next, next
+		next++
+		stack = append(stack, i)
+		onStack[i] = true
+
+		for _, j := range successors[i] {
+			if placed[j] {
+				continue
+			}
+
+			if index[j] == -1 {
+				visit(j)
+				if low[j] < low[i] {
+					low[i] = low[j]
+				}
+			} else if onStack[j] && index[j] < low[i] {
+				low[i] = index[j]
+			}
+		}
+
+		if low[i] != index[i] {
+			return
+		}
+
+		var component []int
+		for {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[j] = false
+			component = append(component, j)
+			if j == i {
+				break
+			}
+		}
+
+		if len(component) > 1 {
+			sort.Ints(component)
+			components = append(components, component)
+		}
+	}
+
+	for i := range successors {
+		if !placed[i] && index[i] == -1 {
+			visit(i)
+		}
+	}
+
+	sort.Slice(components, func(a, b int) bool {
+		return components[a][0] < components[b][0]
+	})
+
+	return components
+}
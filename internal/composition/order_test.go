@@ -0,0 +1,69 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package composition
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+func noop(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+	return p, nil
+}
+
+func TestResolveOrdersByConstraintsThenPriority(t *testing.T) {
+	cd := CallbackDefinitions{}
+
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Save"})
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Notify", After: []string{"Save"}})
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Validate", Before: []string{"Save"}})
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Audit", Priority: 10})
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Metrics"})
+
+	resolved, issues := cd.Resolve()
+
+	assert.Empty(t, issues)
+	assert.Equal(t, []string{"Audit", "Validate", "Save", "Notify", "Metrics"}, resolved.EntryFunctionChain)
+	assert.Equal(t, "Save", cd.OnEntryFn[0].Config.Name)
+}
+
+func TestResolveReportsCyclesAndUnknownReferences(t *testing.T) {
+	cd := CallbackDefinitions{}
+
+	cd.AddExit(nil, noop, plinko.OperationConfig{Name: "Release", Before: []string{"Refund"}})
+	cd.AddExit(nil, noop, plinko.OperationConfig{Name: "Refund", Before: []string{"Release"}})
+	cd.AddExit(nil, noop, plinko.OperationConfig{Name: "Log", After: []string{"Missing"}})
+
+	resolved, issues := cd.Resolve()
+
+	assert.Equal(t, []OrderingIssue{
+		{Chain: "exit", Operation: "Log", Reference: "Missing"},
+		{Chain: "exit", Cycle: []string{"Release", "Refund"}},
+	}, issues)
+	assert.Equal(t, []string{"Log", "Release", "Refund"}, resolved.ExitFunctionChain)
+}
+
+func TestResolveReportsOnlyTheOperationsInACycle(t *testing.T) {
+	cd := CallbackDefinitions{}
+
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Notify", After: []string{"Charge"}})
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Reserve", Before: []string{"Charge"}})
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Charge", Before: []string{"Reserve"}})
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Pack", After: []string{"Ship"}})
+	cd.AddEntry(nil, noop, plinko.OperationConfig{Name: "Ship", After: []string{"Pack"}})
+
+	resolved, issues := cd.Resolve()
+
+	assert.Equal(t, []OrderingIssue{
+		{Chain: "entry", Cycle: []string{"Reserve", "Charge"}},
+		{Chain: "entry", Cycle: []string{"Pack", "Ship"}},
+	}, issues)
+	assert.Equal(t, []string{"Notify", "Reserve", "Charge", "Pack", "Ship"}, resolved.EntryFunctionChain)
+}
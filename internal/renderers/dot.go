@@ -29,11 +29,30 @@ func NewDot(w io.Writer) *Dot {
 
 func (d *Dot) Render(graph plinko.Graph) error {
 	d.beginGraph()
+
+	var roots []plinko.State
+	substates := map[plinko.State][]plinko.State{}
+	configs := map[plinko.State]plinko.StateConfig{}
 	graph.Nodes(func(state plinko.State, info plinko.StateConfig) {
-		d.node(string(state), info.Name, info.Description)
+		configs[state] = info
+		if info.Parent == "" {
+			roots = append(roots, state)
+		} else {
+			substates[info.Parent] = append(substates[info.Parent], state)
+		}
 	})
-	graph.Edges(func(state, destinationState plinko.State, name plinko.Trigger) {
-		d.edge(string(state), string(destinationState), string(name))
+
+	for _, state := range roots {
+		d.cluster(state, substates, configs)
+	}
+
+	graph.Edges(func(state, destinationState plinko.State, name plinko.Trigger, config plinko.TriggerConfig) {
+		label := string(name)
+		if marker := historyMarker(config.History); marker != "" {
+			label += " " + marker
+		}
+		label += guardLabel(config.Guard)
+		d.edge(string(state), string(destinationState), label)
 	})
 	d.endGraph()
 	return d.err
@@ -55,6 +74,28 @@ func (d *Dot) edge(a, b, label string) {
 	d.write([]byte(fmt.Sprintf(d.style.templates.edge, a, b, label)))
 }
 
+// cluster writes the node for the state, grouping composite states and their substates into
+// clusters.  The regions of a parallel state are drawn with a dashed border.
+func (d *Dot) cluster(state plinko.State, substates map[plinko.State][]plinko.State, configs map[plinko.State]plinko.StateConfig) {
+	info := configs[state]
+	if len(substates[state]) == 0 {
+		d.node(string(state), info.Name, info.Description)
+		return
+	}
+
+	style := d.style.templates.cluster
+	if parent, ok := configs[info.Parent]; ok && parent.Parallel {
+		style = d.style.templates.region
+	}
+
+	d.write([]byte(fmt.Sprintf(style, state, info.Name)))
+	d.node(string(state), info.Name, info.Description)
+	for _, substate := range substates[state] {
+		d.cluster(substate, substates, configs)
+	}
+	d.write([]byte("}\n"))
+}
+
 func (d *Dot) node(name, label, description string) {
 	d.write([]byte(fmt.Sprintf(d.style.templates.node, name, label, description)))
 }
@@ -78,8 +119,10 @@ type dotDefaultStyles struct {
 }
 
 type dotTemplates struct {
-	node string
-	edge string
+	node    string
+	edge    string
+	cluster string
+	region  string
 }
 
 var defaultDotStyle = dotStylesheet{
@@ -95,7 +138,9 @@ start=1251;
 		edge:  "edge [constraint=true, fontname = \"sans-serif\"];\n",
 	},
 	templates: dotTemplates{
-		node: `"%s" [label=<<TABLE STYLE="ROUNDED" BGCOLOR="orange" BORDER="1" CELLSPACING="0" WIDTH="20"><TR><TD BORDER="0">%s</TD></TR><TR><TD BORDER="1" SIDES="t">%s</TD></TR></TABLE>>];` + "\n",
-		edge: "\"%s\" -> \"%s\"[label=\"%s\"];\n",
+		node:    `"%s" [label=<<TABLE STYLE="ROUNDED" BGCOLOR="orange" BORDER="1" CELLSPACING="0" WIDTH="20"><TR><TD BORDER="0">%s</TD></TR><TR><TD BORDER="1" SIDES="t">%s</TD></TR></TABLE>>];` + "\n",
+		edge:    "\"%s\" -> \"%s\"[label=\"%s\"];\n",
+		cluster: "subgraph \"cluster_%s\" {\nlabel=\"%s\";\n",
+		region:  "subgraph \"cluster_%s\" {\nlabel=\"%s\";\nstyle=dashed;\n",
 	},
 }
@@ -8,12 +8,14 @@ package renderers_test
 
 import (
 	"bytes"
+	"context"
 	"testing"
 
 	"github.com/shipt/plinko"
 	"github.com/shipt/plinko/internal/renderers"
 	"github.com/shipt/plinko/pkg/config"
 	"github.com/shipt/plinko/pkg/config/state"
+	"github.com/shipt/plinko/pkg/guard"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -50,3 +52,63 @@ func Test_CreateDot(t *testing.T) {
 	assert.Contains(t, buf.String(), `Very much new order`)
 	assert.Contains(t, buf.String(), `Where it all begins`)
 }
+
+func Test_CreateDotWithRegions(t *testing.T) {
+	p := config.CreatePlinkoDefinition()
+
+	p.Configure("Processing", state.Parallel())
+	p.Configure("Fulfillment", state.SubstateOf("Processing"))
+	p.Configure("Picking", state.SubstateOf("Fulfillment"), state.Initial())
+	p.Configure("Payment", state.SubstateOf("Processing"))
+	p.Configure("Authorizing", state.SubstateOf("Payment"), state.Initial())
+
+	buf := bytes.NewBufferString("")
+
+	err := p.Render(renderers.NewDot(buf))
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "subgraph \"cluster_Processing\" {\nlabel=\"Processing\";\n")
+	assert.Contains(t, buf.String(), "subgraph \"cluster_Payment\" {\nlabel=\"Payment\";\nstyle=dashed;\n")
+}
+
+func Test_CreateDotWithGuards(t *testing.T) {
+	p := config.CreatePlinkoDefinition()
+
+	p.Configure(Opened).
+		PermitGuard(guard.And(guard.Named("IsCancellable", nil), guard.Not(guard.Named("IsFlagged", nil))), "Cancel", Canceled).
+		PermitIf(nil, "Claim", Claimed)
+
+	p.Configure(Canceled)
+	p.Configure(Claimed)
+
+	buf := bytes.NewBufferString("")
+
+	err := p.Render(renderers.NewDot(buf))
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), `"Opened" -> "Canceled"[label="Cancel [IsCancellable && !IsFlagged]"];`)
+	assert.Contains(t, buf.String(), `"Opened" -> "Claimed"[label="Claim"];`)
+
+	buf.Reset()
+
+	err = p.Render(renderers.NewUML(buf))
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "Opened --> Canceled : Cancel [IsCancellable && !IsFlagged]")
+}
+
+func IsCancellable(_ context.Context, _ plinko.Payload, _ plinko.TransitionInfo) bool {
+	return true
+}
+
+func Test_CreateUMLWithBooleanGuards(t *testing.T) {
+	p := config.CreatePlinkoDefinition()
+
+	p.Configure(Opened).
+		PermitWhen(IsCancellable, "Cancel", Canceled)
+
+	p.Configure(Canceled)
+
+	buf := bytes.NewBufferString("")
+
+	err := p.Render(renderers.NewUML(buf))
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "Opened --> Canceled : Cancel [IsCancellable]")
+}
@@ -26,15 +26,79 @@ func NewUML(w io.Writer) *UML {
 func (d *UML) Render(graph plinko.Graph) error {
 	d.write([]byte("@startuml\n"))
 
+	var roots []plinko.State
+	substates := map[plinko.State][]plinko.State{}
+	configs := map[plinko.State]plinko.StateConfig{}
+	graph.Nodes(func(state plinko.State, info plinko.StateConfig) {
+		configs[state] = info
+		if info.Parent == "" {
+			roots = append(roots, state)
+		} else {
+			substates[info.Parent] = append(substates[info.Parent], state)
+		}
+	})
+
+	for _, state := range roots {
+		d.compositeState(state, substates, configs, "")
+	}
+
 	firstEdge := true
-	graph.Edges(func(state, destinationState plinko.State, name plinko.Trigger) {
+	graph.Edges(func(state, destinationState plinko.State, name plinko.Trigger, config plinko.TriggerConfig) {
 		if firstEdge {
 			d.write([]byte(fmt.Sprintf("[*] -> %s \n", state)))
 			firstEdge = false
 		}
-		d.write([]byte(fmt.Sprintf("%s --> %s : %s\n", state, destinationState, name)))
+		d.write([]byte(fmt.Sprintf("%s --> %s%s : %s%s\n", state, destinationState, historyMarker(config.History), name, guardLabel(config.Guard))))
 	})
 
 	d.write([]byte("@enduml"))
 	return nil
 }
+
+// compositeState declares the nesting of substates within their composite states, along with
+// the initial and final states of each.  The regions of a parallel state are separated by "--".
+// Top-level states without substates need no declaration.
+func (d *UML) compositeState(state plinko.State, substates map[plinko.State][]plinko.State, configs map[plinko.State]plinko.StateConfig, indent string) {
+	if len(substates[state]) == 0 {
+		if indent != "" {
+			d.write([]byte(fmt.Sprintf("%sstate %s\n", indent, state)))
+		}
+		return
+	}
+
+	d.write([]byte(fmt.Sprintf("%sstate %s {\n", indent, state)))
+	for i, substate := range substates[state] {
+		if i > 0 && configs[state].Parallel {
+			d.write([]byte(fmt.Sprintf("%s  --\n", indent)))
+		}
+
+		d.compositeState(substate, substates, configs, indent+"  ")
+
+		if configs[substate].Initial {
+			d.write([]byte(fmt.Sprintf("%s  [*] --> %s\n", indent, substate)))
+		}
+		if configs[substate].Final {
+			d.write([]byte(fmt.Sprintf("%s  %s --> [*]\n", indent, substate)))
+		}
+	}
+	d.write([]byte(fmt.Sprintf("%s}\n", indent)))
+}
+
+func historyMarker(history plinko.HistoryType) string {
+	switch history {
+	case plinko.ShallowHistory:
+		return "[H]"
+	case plinko.DeepHistory:
+		return "[H*]"
+	}
+
+	return ""
+}
+
+func guardLabel(guard string) string {
+	if guard == "" {
+		return ""
+	}
+
+	return " [" + guard + "]"
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/shipt/plinko/internal/renderers"
 	"github.com/shipt/plinko/pkg/config"
+	"github.com/shipt/plinko/pkg/config/state"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,3 +37,68 @@ func Test_CreateUML(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, buf.String(), "UnderReview --> PublishedOrder : CompleteReview")
 }
+
+func Test_CreateUMLWithHistory(t *testing.T) {
+	p := config.CreatePlinkoDefinition()
+
+	p.Configure("InProgress").
+		Permit("Hold", "OnHold")
+
+	p.Configure("Picking", state.SubstateOf("InProgress")).
+		Permit("Pack", "Packing")
+
+	p.Configure("Packing", state.SubstateOf("InProgress"))
+
+	p.Configure("OnHold").
+		PermitToHistory("Resume", "InProgress").
+		PermitToDeepHistory("ResumeDeep", "InProgress")
+
+	buf := bytes.NewBufferString("")
+
+	err := p.Render(renderers.NewUML(buf))
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "state InProgress {\n  state Picking\n  state Packing\n}\n")
+	assert.Contains(t, buf.String(), "OnHold --> InProgress[H] : Resume")
+	assert.Contains(t, buf.String(), "OnHold --> InProgress[H*] : ResumeDeep")
+}
+
+func Test_CreateUMLWithRegions(t *testing.T) {
+	p := config.CreatePlinkoDefinition()
+
+	p.Configure("Processing", state.Parallel()).
+		PermitJoin("Complete", "Completed")
+
+	p.Configure("Fulfillment", state.SubstateOf("Processing"))
+	p.Configure("Picking", state.SubstateOf("Fulfillment"), state.Initial()).
+		Permit("Pack", "Packed")
+	p.Configure("Packed", state.SubstateOf("Fulfillment"), state.Final())
+
+	p.Configure("Payment", state.SubstateOf("Processing"))
+	p.Configure("Authorizing", state.SubstateOf("Payment"), state.Initial()).
+		Permit("Capture", "Captured")
+	p.Configure("Captured", state.SubstateOf("Payment"), state.Final())
+
+	p.Configure("Completed")
+
+	buf := bytes.NewBufferString("")
+
+	err := p.Render(renderers.NewUML(buf))
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), `state Processing {
+  state Fulfillment {
+    state Picking
+    [*] --> Picking
+    state Packed
+    Packed --> [*]
+  }
+  --
+  state Payment {
+    state Authorizing
+    [*] --> Authorizing
+    state Captured
+    Captured --> [*]
+  }
+}
+`)
+	assert.Contains(t, buf.String(), "Processing --> Completed : Complete")
+}
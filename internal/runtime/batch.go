@@ -0,0 +1,116 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+func newBatchConfig(opts ...plinko.BatchOption) plinko.BatchConfig {
+	c := plinko.BatchConfig{
+		Concurrency: plinko.DefaultBatchConcurrency,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.Concurrency <= 0 {
+		c.Concurrency = plinko.DefaultBatchConcurrency
+	}
+
+	return c
+}
+
+// FireBatch fires the trigger on every payload, a bounded number at a time.  When some payloads
+// fail, the result reports each of them and a plinkoerror.PlinkoBatchError is returned.  Once
+// the context is done no further payloads are fired; those left carry the context error, which
+// is also returned.  A batch that completed every payload before the context ended does not
+// return the context error.
+func (psm plinkoStateMachine) FireBatch(ctx context.Context, payloads []plinko.Payload, trigger plinko.Trigger, opts ...plinko.BatchOption) (plinko.BatchResult, error) {
+	cfg := newBatchConfig(opts...)
+
+	items := make([]plinko.BatchItemResult, len(payloads))
+	canceled := make([]bool, len(payloads))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.Concurrency && w < len(payloads); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				items[i], canceled[i] = psm.fireBatchItem(ctx, payloads[i], trigger, cfg)
+			}
+		}()
+	}
+
+	next := 0
+dispatch:
+	for ; next < len(payloads); next++ {
+		select {
+		case indexes <- next:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	// the payloads never handed to a worker once the context ended
+	for i := next; i < len(payloads); i++ {
+		items[i], canceled[i] = plinko.BatchItemResult{Payload: payloads[i], Err: ctx.Err()}, true
+	}
+
+	result := plinko.BatchResult{Items: items}
+	unfired := false
+	for i, item := range result.Items {
+		unfired = unfired || canceled[i]
+
+		switch {
+		case item.Skipped:
+			result.Skipped++
+		case item.Err != nil:
+			result.Failed++
+		default:
+			result.Succeeded++
+		}
+	}
+
+	if unfired {
+		return result, ctx.Err()
+	}
+
+	if result.Failed > 0 {
+		return result, plinkoerror.CreatePlinkoBatchError(result.Failed, len(payloads), fmt.Sprintf("Trigger '%s' failed for %d of %d payloads", trigger, result.Failed, len(payloads)))
+	}
+
+	return result, nil
+}
+
+// fireBatchItem fires the trigger on a payload of the batch, reporting whether the payload was
+// left unfired because the context was done.
+func (psm plinkoStateMachine) fireBatchItem(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger, cfg plinko.BatchConfig) (plinko.BatchItemResult, bool) {
+	if err := ctx.Err(); err != nil {
+		return plinko.BatchItemResult{Payload: payload, Err: err}, true
+	}
+
+	if cfg.SkipIfCannotFire {
+		if err := psm.CanFire(ctx, payload, trigger); err != nil {
+			return plinko.BatchItemResult{Payload: payload, Err: err, Skipped: true}, false
+		}
+	}
+
+	payload, err := psm.Fire(ctx, payload, trigger)
+
+	return plinko.BatchItemResult{Payload: payload, Err: err}, false
+}
@@ -0,0 +1,142 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func createBatchStateMachine(entry plinko.Operation) plinko.StateMachine {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		OnEntry(entry)
+
+	return p.Compile().StateMachine
+}
+
+func TestFireBatch(t *testing.T) {
+	var active, peak int32
+	sm := createBatchStateMachine(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+
+		for {
+			m := atomic.LoadInt32(&peak)
+			if n <= m || atomic.CompareAndSwapInt32(&peak, m, n) {
+				break
+			}
+		}
+
+		if !p.(*testPayload).condition {
+			return p, errors.New("cancel failed")
+		}
+
+		p.(*testPayload).state = t.GetDestination()
+		return p, nil
+	})
+
+	payloads := []plinko.Payload{
+		&testPayload{state: Created, condition: true},
+		&testPayload{state: Opened, condition: true},
+		&testPayload{state: Created, condition: false},
+		&testPayload{state: Canceled, condition: true},
+	}
+	for i := 0; i < 20; i++ {
+		payloads = append(payloads, &testPayload{state: Created, condition: true})
+	}
+
+	result, err := sm.FireBatch(context.TODO(), payloads, Cancel, func(c *plinko.BatchConfig) { c.Concurrency = 3 })
+
+	var pbe *plinkoerror.PlinkoBatchError
+	assert.True(t, errors.As(err, &pbe))
+	assert.Equal(t, 2, pbe.Failed)
+	assert.Equal(t, 24, pbe.Total)
+
+	assert.Equal(t, 24, len(result.Items))
+	assert.Equal(t, 22, result.Succeeded)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, Canceled, result.Items[1].Payload.GetState())
+	assert.EqualError(t, result.Items[2].Err, "cancel failed")
+
+	var pte *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(result.Items[3].Err, &pte))
+	assert.True(t, atomic.LoadInt32(&peak) <= 3)
+
+	payloads[2].(*testPayload).state = Created
+	payloads[3].(*testPayload).state = Canceled
+
+	result, err = sm.FireBatch(context.TODO(), payloads[2:4], Cancel, func(c *plinko.BatchConfig) { c.SkipIfCannotFire = true })
+	assert.True(t, errors.As(err, &pbe))
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, 1, result.Skipped)
+	assert.True(t, result.Items[1].Skipped)
+	assert.True(t, errors.As(result.Items[1].Err, &pte))
+
+	result, err = sm.FireBatch(context.TODO(), nil, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(result.Items))
+}
+
+func TestFireBatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	var fired int32
+	sm := createBatchStateMachine(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+		if atomic.AddInt32(&fired, 1) == 2 {
+			cancel()
+		}
+
+		p.(*testPayload).state = t.GetDestination()
+		return p, nil
+	})
+
+	var payloads []plinko.Payload
+	for i := 0; i < 10; i++ {
+		payloads = append(payloads, &testPayload{state: Created})
+	}
+
+	result, err := sm.FireBatch(ctx, payloads, Cancel, func(c *plinko.BatchConfig) { c.Concurrency = 1 })
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 2, result.Succeeded)
+	assert.Equal(t, 8, result.Failed)
+	assert.Equal(t, context.Canceled, result.Items[9].Err)
+	assert.Equal(t, payloads[9], result.Items[9].Payload)
+}
+
+func TestFireBatchCanceledAfterCompleting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	var fired int32
+	sm := createBatchStateMachine(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+		if atomic.AddInt32(&fired, 1) == 3 {
+			cancel()
+		}
+
+		p.(*testPayload).state = t.GetDestination()
+		return p, nil
+	})
+
+	payloads := []plinko.Payload{&testPayload{state: Created}, &testPayload{state: Created}, &testPayload{state: Created}}
+
+	result, err := sm.FireBatch(ctx, payloads, Cancel, func(c *plinko.BatchConfig) { c.Concurrency = 1 })
+	assert.Nil(t, err)
+	assert.Equal(t, 3, result.Succeeded)
+}
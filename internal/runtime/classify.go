@@ -0,0 +1,67 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/internal/sideeffects"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+type failureRouteKey struct{}
+
+// classifyFailure handles a transition that failed with a classified error.  A retryable error
+// leaves the payload where it is, while a fatal error routes the payload to the failure state of
+// the definition when one is configured.  Both are signaled to the side effects.
+//
+// Routing is a transition of its own from the source state to the failure state, running the
+// entry operations, side effects and journal like any other.  The exit operations of the source
+// state ran as part of the failed transition and are not run again.  A routed failure is
+// reported with a plinkoerror.PlinkoFailureStateError, so FireByID knows to save the payload.
+func (psm plinkoStateMachine) classifyFailure(ctx context.Context, payload plinko.Payload, td *sideeffects.TransitionDef, source, region *InternalStateDefinition, err error, start time.Time) (plinko.Payload, error) {
+	if plinkoerror.IsRetryable(err) {
+		sideeffects.Dispatch(ctx, plinko.RetryableFailure, psm.sideEffects.Lookup(td), payload, td, time.Since(start).Milliseconds())
+		return payload, err
+	}
+
+	failure := (*psm.pd.States)[psm.pd.Config.FailureState]
+	if !plinkoerror.IsFatal(err) || failure == nil || region != nil {
+		return payload, err
+	}
+
+	// a fatal error while routing to the failure state is not routed again
+	if routingFailure(ctx) {
+		return payload, err
+	}
+
+	ftd := &sideeffects.TransitionDef{
+		Source:      source.State,
+		Destination: failure.State,
+		Trigger:     td.Trigger,
+	}
+
+	payload, routeErr := psm.runTransition(context.WithValue(ctx, failureRouteKey{}, true), payload, ftd, source, failure, nil, time.Now())
+	if routeErr != nil {
+		// the payload did not reach the failure state, which is reported in place of the fatal error
+		return payload, routeErr
+	}
+
+	sideeffects.Dispatch(ctx, plinko.FatalFailure, psm.sideEffects.Lookup(ftd), payload, ftd, time.Since(start).Milliseconds())
+
+	return payload, plinkoerror.CreatePlinkoFailureStateError(failure.State, err, fmt.Sprintf("Payload routed to failure state '%s': %v", failure.State, err))
+}
+
+// routingFailure reports if the transition routes a payload to the failure state.
+func routingFailure(ctx context.Context) bool {
+	routing, _ := ctx.Value(failureRouteKey{}).(bool)
+
+	return routing
+}
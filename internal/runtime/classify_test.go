@@ -0,0 +1,149 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/config/definition"
+	"github.com/shipt/plinko/pkg/store/memory"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+const Failed plinko.State = "Failed"
+
+func TestFireWithClassifiedErrors(t *testing.T) {
+	var actions []plinko.StateAction
+	var failedTransition plinko.TransitionInfo
+	var exits int
+
+	p := createPlinkoDefinition(definition.WithFailureState(Failed))
+
+	p.FilteredSideEffect(plinko.AllowRetryableFailure|plinko.AllowFatalFailure|plinko.AllowAfterTransition, func(_ context.Context, action plinko.StateAction, _ plinko.Payload, t plinko.TransitionInfo, _ int64) {
+		actions = append(actions, action)
+		failedTransition = t
+	})
+
+	p.Configure(Created).
+		OnExit(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			exits++
+			return p, nil
+		}).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, plinkoerror.Retryable(errors.New("inventory unavailable"))
+		})
+
+	p.Configure(Canceled).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, plinkoerror.Fatal(errors.New("refund rejected"))
+		})
+
+	p.Configure(Failed).
+		OnEntry(TransitionFn(false))
+
+	co := p.Compile()
+	psm := co.StateMachine
+
+	payload := &testPayload{state: Created}
+	pr, err := psm.Fire(context.TODO(), payload, Open)
+	assert.True(t, plinkoerror.IsRetryable(err))
+	assert.Equal(t, Created, pr.GetState())
+	assert.Equal(t, []plinko.StateAction{plinko.RetryableFailure}, actions)
+	assert.Equal(t, Opened, failedTransition.GetDestination())
+
+	actions = nil
+	exits = 0
+	pr, results, err := psm.FireWithResults(context.TODO(), payload, Cancel)
+	assert.True(t, plinkoerror.IsFatal(err))
+	assert.Equal(t, "Payload routed to failure state 'Failed': refund rejected", err.Error())
+
+	var fse *plinkoerror.PlinkoFailureStateError
+	assert.True(t, errors.As(err, &fse))
+	assert.Equal(t, Failed, fse.State)
+
+	assert.Equal(t, Failed, pr.GetState())
+	assert.Equal(t, 1, exits)
+	assert.Equal(t, []plinko.StateAction{plinko.AfterTransition, plinko.FatalFailure}, actions)
+	assert.Equal(t, Failed, failedTransition.GetDestination())
+
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, Canceled, results[0].Destination)
+	assert.NotNil(t, results[0].Err)
+	assert.Equal(t, Failed, results[1].Destination)
+	assert.Nil(t, results[1].Err)
+}
+
+func TestFireByIDSavesPayloadRoutedToFailureState(t *testing.T) {
+	store := memory.NewStore()
+
+	p := createPlinkoDefinition(definition.WithStore(store), definition.WithFailureState(Failed))
+
+	p.Configure(Created).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, plinkoerror.Fatal(errors.New("refund rejected"))
+		})
+
+	p.Configure(Failed).
+		OnEntry(TransitionFn(false))
+
+	psm := p.Compile().StateMachine
+
+	_, err := store.Save(context.TODO(), "order-1", &testPayload{state: Created}, 0)
+	assert.Nil(t, err)
+
+	pr, err := psm.FireByID(context.TODO(), "order-1", Cancel)
+	assert.True(t, plinkoerror.IsFatal(err))
+	assert.Equal(t, Failed, pr.GetState())
+
+	stored, version, err := store.Load(context.TODO(), "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, Failed, stored.GetState())
+}
+
+func TestFireWithFatalErrorWithoutFailureState(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, plinkoerror.Fatal(errors.New("refund rejected"))
+		})
+
+	pr, err := p.Compile().StateMachine.Fire(context.TODO(), &testPayload{state: Created}, Cancel)
+	assert.True(t, plinkoerror.IsFatal(err))
+	assert.Equal(t, Created, pr.GetState())
+}
+
+func TestCompileWithUndefinedFailureState(t *testing.T) {
+	p := createPlinkoDefinition(definition.WithFailureState(Failed))
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		Permit(Cancel, Created)
+
+	co := p.Compile()
+
+	assert.Equal(t, []plinko.CompilerMessage{
+		{CompileMessage: plinko.CompileError, Message: "State 'Failed' undefined: it is configured as the failure state."},
+	}, co.Messages)
+}
@@ -12,11 +12,12 @@ import (
 
 	"github.com/shipt/plinko"
 	"github.com/shipt/plinko/internal/renderers"
+	"github.com/shipt/plinko/internal/sideeffects"
 )
 
 func (pd PlinkoDefinition) Compile() plinko.CompilerOutput {
 
-	var compilerMessages []plinko.CompilerMessage
+	compilerMessages := append([]plinko.CompilerMessage(nil), pd.Abs.CompilerMessages...)
 
 	for _, def := range pd.Abs.TriggerDefinitions {
 		if !findDestinationState(pd.Abs.States, def.DestinationState) {
@@ -28,7 +29,7 @@ func (pd PlinkoDefinition) Compile() plinko.CompilerOutput {
 	}
 
 	for _, def := range pd.Abs.StateDefinitions {
-		if len(def.Triggers) == 0 {
+		if !pd.hasTriggers(def) && !pd.hasSubstates(def.State) && !def.info.Final {
 			compilerMessages = append(compilerMessages, plinko.CompilerMessage{
 				CompileMessage: plinko.CompileWarning,
 				Message:        fmt.Sprintf("State '%s' is a state without any triggers (deadend state).", def.State),
@@ -36,9 +37,28 @@ func (pd PlinkoDefinition) Compile() plinko.CompilerOutput {
 		}
 	}
 
+	if state := pd.Config.FailureState; state != "" && !findDestinationState(pd.Abs.States, state) {
+		compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+			CompileMessage: plinko.CompileError,
+			Message:        fmt.Sprintf("State '%s' undefined: it is configured as the failure state.", state),
+		})
+	}
+
+	compilerMessages = append(compilerMessages, compileHierarchy(pd)...)
+	compilerMessages = append(compilerMessages, compileDeferredTriggers(pd)...)
+	compilerMessages = append(compilerMessages, compileEntrySources(pd)...)
+
 	psm := plinkoStateMachine{
-		pd: pd,
+		pd:             pd,
+		sideEffects:    compileSideEffects(pd),
+		historyTargets: compileHistoryTargets(pd),
 	}
+	compilerMessages = append(compilerMessages, compileOperations(pd, &psm)...)
+	compilerMessages = append(compilerMessages, compileRegions(pd, &psm)...)
+
+	psm.step = applyMiddleware(pd.Middleware, psm.transition)
+	psm.canFire = applyMiddleware(pd.Middleware, psm.canFireTransition)
+	psm.fire = psm.fireTransition
 
 	co := plinko.CompilerOutput{
 		Messages:     compilerMessages,
@@ -48,6 +68,203 @@ func (pd PlinkoDefinition) Compile() plinko.CompilerOutput {
 	return co
 }
 
+// compileHierarchy validates the parent of every substate and the targets of history transitions.
+func compileHierarchy(pd PlinkoDefinition) []plinko.CompilerMessage {
+	var compilerMessages []plinko.CompilerMessage
+
+	for _, def := range pd.Abs.StateDefinitions {
+		if def.info.Parent == "" {
+			continue
+		}
+
+		if _, ok := (*pd.States)[def.info.Parent]; !ok {
+			compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+				CompileMessage: plinko.CompileError,
+				Message:        fmt.Sprintf("State '%s' undefined: State '%s' declares it as its parent state.", def.info.Parent, def.State),
+			})
+			continue
+		}
+
+		if isCyclic(pd, def) {
+			compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+				CompileMessage: plinko.CompileError,
+				Message:        fmt.Sprintf("State '%s' is nested within itself through its parent states.", def.State),
+			})
+		}
+	}
+
+	for _, def := range pd.Abs.StateDefinitions {
+		for _, td := range def.Triggers {
+			if td.Config.History != plinko.NoHistory && !pd.hasSubstates(td.DestinationState) {
+				compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+					CompileMessage: plinko.CompileError,
+					Message:        fmt.Sprintf("State '%s' has no substates: Trigger '%s' declares a history transition to this state.", td.DestinationState, td.Name),
+				})
+			}
+		}
+	}
+
+	return compilerMessages
+}
+
+func isCyclic(pd PlinkoDefinition, sd *InternalStateDefinition) bool {
+	visited := map[plinko.State]bool{}
+
+	for sd != nil && sd.info.Parent != "" {
+		if visited[sd.State] {
+			return true
+		}
+		visited[sd.State] = true
+
+		sd = (*pd.States)[sd.info.Parent]
+	}
+
+	return false
+}
+
+func (pd PlinkoDefinition) hasTriggers(sd *InternalStateDefinition) bool {
+	for _, s := range pd.lineage(sd) {
+		if len(s.Triggers) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileHistoryTargets collects the composite states that history transitions return to, as only
+// those need their last active substate recorded.
+func compileHistoryTargets(pd PlinkoDefinition) map[plinko.State]bool {
+	targets := map[plinko.State]bool{}
+
+	for _, def := range pd.Abs.StateDefinitions {
+		for _, td := range def.Triggers {
+			if td.Config.History != plinko.NoHistory {
+				targets[td.DestinationState] = true
+			}
+		}
+	}
+
+	return targets
+}
+
+// compileDeferredTriggers warns about deferred triggers that no state reachable from the
+// deferring state permits, as they would never be replayed.
+func compileDeferredTriggers(pd PlinkoDefinition) []plinko.CompilerMessage {
+	var compilerMessages []plinko.CompilerMessage
+
+	for _, def := range pd.Abs.StateDefinitions {
+		for trigger := range def.Deferred {
+			if !isTriggerReachable(pd, def.State, trigger) {
+				compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+					CompileMessage: plinko.CompileWarning,
+					Message:        fmt.Sprintf("State '%s' defers Trigger '%s', but no state reachable from it permits the trigger.", def.State, trigger),
+				})
+			}
+		}
+	}
+
+	return compilerMessages
+}
+
+func isTriggerReachable(pd PlinkoDefinition, state plinko.State, trigger plinko.Trigger) bool {
+	visited := map[plinko.State]bool{state: true}
+	pending := []plinko.State{state}
+
+	visit := func(state plinko.State) {
+		if !visited[state] {
+			visited[state] = true
+			pending = append(pending, state)
+		}
+	}
+
+	for len(pending) > 0 {
+		sd := (*pd.States)[pending[0]]
+		pending = pending[1:]
+
+		if sd == nil {
+			continue
+		}
+
+		if td, _ := pd.resolveTrigger(sd, trigger); td != nil {
+			return true
+		}
+
+		for _, s := range pd.lineage(sd) {
+			for _, td := range s.Triggers {
+				visit(td.DestinationState)
+
+				if td.Config.History == plinko.NoHistory {
+					continue
+				}
+
+				// a history transition may land in any substate of its composite state
+				for _, def := range pd.Abs.StateDefinitions {
+					if pd.isDescendant(def.State, td.DestinationState) {
+						visit(def.State)
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// compileEntrySources warns about OnEntryFrom operations whose source state has no transition
+// into the state, as they would never run.
+func compileEntrySources(pd PlinkoDefinition) []plinko.CompilerMessage {
+	var compilerMessages []plinko.CompilerMessage
+
+	for _, def := range pd.Abs.StateDefinitions {
+		for source := range def.EntrySources {
+			if !pd.hasTransitionInto(source, def.State) {
+				compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+					CompileMessage: plinko.CompileWarning,
+					Message:        fmt.Sprintf("State '%s' declares an entry operation from State '%s', but that state has no transition into it.", def.State, source),
+				})
+			}
+		}
+	}
+
+	return compilerMessages
+}
+
+// hasTransitionInto reports if the source state, a state it inherits triggers from, or one of its
+// substates permits a trigger that enters the state or one of its substates.
+func (pd PlinkoDefinition) hasTransitionInto(source, state plinko.State) bool {
+	from := pd.lineage((*pd.States)[source])
+	for _, sd := range pd.Abs.StateDefinitions {
+		if pd.isDescendant(sd.State, source) {
+			from = append(from, sd)
+		}
+	}
+
+	for _, sd := range from {
+		for _, td := range sd.Triggers {
+			if td.DestinationState == state || pd.isDescendant(td.DestinationState, state) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// compileSideEffects resolves the side effects for every declared transition up front
+// so dispatching does not need to visit handlers filtered to other states or triggers.
+func compileSideEffects(pd PlinkoDefinition) *sideeffects.Index {
+	ix := sideeffects.NewIndex(pd.SideEffects)
+
+	for _, sd := range pd.Abs.StateDefinitions {
+		for _, td := range sd.Triggers {
+			ix.Precompute(sd.State, td.DestinationState, td.Name)
+		}
+	}
+
+	return ix
+}
+
 func (pd PlinkoDefinition) RenderUml() (plinko.Uml, error) {
 	cm := pd.Compile()
 
@@ -69,10 +286,10 @@ func (pd PlinkoDefinition) Render(renderer plinko.Renderer) error {
 }
 
 // Edges implements Edges method of the plinko.Graph interface
-func (pd PlinkoDefinition) Edges(edgeFunc func(state, destinationState plinko.State, name plinko.Trigger)) {
+func (pd PlinkoDefinition) Edges(edgeFunc func(state, destinationState plinko.State, name plinko.Trigger, config plinko.TriggerConfig)) {
 	for _, sd := range pd.Abs.StateDefinitions {
 		for _, td := range sd.Triggers {
-			edgeFunc(sd.State, td.DestinationState, td.Name)
+			edgeFunc(sd.State, td.DestinationState, td.Name, td.Config)
 		}
 	}
 }
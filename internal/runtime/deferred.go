@@ -0,0 +1,75 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+// deferTrigger records the trigger on the payload so it can be replayed once a later state permits it.
+func deferTrigger(payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, error) {
+	store, ok := payload.(plinko.DeferredTriggerStore)
+	if !ok {
+		return payload, plinkoerror.CreatePlinkoTriggerError(trigger, fmt.Sprintf("Trigger '%s' is deferred by state %s, but the payload does not implement DeferredTriggerStore", trigger, payload.GetState()))
+	}
+
+	deferred := store.GetDeferredTriggers()
+	for _, t := range deferred {
+		if t == trigger {
+			return payload, nil
+		}
+	}
+
+	store.SetDeferredTriggers(append(deferred, trigger))
+
+	return payload, nil
+}
+
+// replayDeferredTrigger enqueues the first deferred trigger the payload's current state
+// accepts.  Triggers the current state still defers are skipped and kept.  Only one trigger is
+// replayed at a time, as the replayed transition changes the state the remaining triggers are
+// evaluated against.
+func (psm plinkoStateMachine) replayDeferredTrigger(ctx context.Context, payload plinko.Payload) {
+	store, ok := payload.(plinko.DeferredTriggerStore)
+	if !ok {
+		return
+	}
+
+	deferred := store.GetDeferredTriggers()
+	for i, trigger := range deferred {
+		if psm.stillDeferred(ctx, payload, trigger) || psm.evaluateCanFire(ctx, payload, trigger) != nil {
+			continue
+		}
+
+		if !plinko.EnqueueTrigger(ctx, trigger) {
+			return
+		}
+
+		remaining := make([]plinko.Trigger, 0, len(deferred)-1)
+		remaining = append(remaining, deferred[:i]...)
+		remaining = append(remaining, deferred[i+1:]...)
+		store.SetDeferredTriggers(remaining)
+
+		return
+	}
+}
+
+// stillDeferred reports if the payload's current state defers the trigger rather than firing it.
+func (psm plinkoStateMachine) stillDeferred(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) bool {
+	sd := (*psm.pd.States)[payload.GetState()]
+	if sd == nil || (sd.info.Parallel && psm.canFireRegion(ctx, payload, sd, trigger)) {
+		return false
+	}
+
+	_, deferred := psm.pd.resolveTrigger(sd, trigger)
+
+	return deferred
+}
@@ -0,0 +1,169 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+const RequestRefund plinko.Trigger = "RequestRefund"
+const Refunded plinko.State = "Refunded"
+
+type deferringPayload struct {
+	testPayload
+	deferred []plinko.Trigger
+}
+
+func (p *deferringPayload) GetDeferredTriggers() []plinko.Trigger {
+	return p.deferred
+}
+
+func (p *deferringPayload) SetDeferredTriggers(triggers []plinko.Trigger) {
+	p.deferred = triggers
+}
+
+func setDeferringState(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+	p.(*deferringPayload).state = t.GetDestination()
+
+	return p, nil
+}
+
+func createDeferringDefinition() plinko.PlinkoDefinition {
+	p := createPlinkoDefinition()
+
+	p.Configure(MarkedAsPickedUp).
+		Defer(RequestRefund).
+		Permit(Deliver, Delivered)
+
+	p.Configure(Delivered).
+		OnEntry(setDeferringState).
+		Permit(RequestRefund, Refunded)
+
+	p.Configure(Refunded).
+		OnEntry(setDeferringState)
+
+	return p
+}
+
+func TestFireDefersTrigger(t *testing.T) {
+	co := createDeferringDefinition().Compile()
+	assert.Equal(t, 1, len(co.Messages))
+	assert.Equal(t, plinko.CompileWarning, co.Messages[0].CompileMessage)
+
+	psm := co.StateMachine
+
+	payload := &deferringPayload{testPayload: testPayload{state: MarkedAsPickedUp}}
+
+	// a trigger Fire defers can be fired, though it does not leave the state
+	assert.Nil(t, psm.CanFire(context.TODO(), payload, RequestRefund))
+	assert.NotNil(t, psm.CanFire(context.TODO(), &testPayload{state: MarkedAsPickedUp}, RequestRefund))
+
+	pr, err := psm.Fire(context.TODO(), payload, RequestRefund)
+	assert.Nil(t, err)
+	assert.Equal(t, MarkedAsPickedUp, pr.GetState())
+	assert.Equal(t, []plinko.Trigger{RequestRefund}, payload.deferred)
+
+	// deferring the same trigger twice only replays it once
+	_, err = psm.Fire(context.TODO(), payload, RequestRefund)
+	assert.Nil(t, err)
+	assert.Equal(t, []plinko.Trigger{RequestRefund}, payload.deferred)
+
+	pr, results, err := psm.FireWithResults(context.TODO(), payload, Deliver)
+	assert.Nil(t, err)
+	assert.Equal(t, Refunded, pr.GetState())
+	assert.Equal(t, 0, len(payload.deferred))
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, RequestRefund, results[1].Trigger)
+}
+
+func TestReplaySkipsTriggersStillDeferred(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(MarkedAsPickedUp).
+		Defer(Return).
+		Defer(Cancel).
+		Permit(Deliver, Delivered)
+
+	p.Configure(Delivered).
+		OnEntry(setDeferringState).
+		Defer(Return).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		OnEntry(setDeferringState)
+
+	psm := p.Compile().StateMachine
+
+	payload := &deferringPayload{testPayload: testPayload{state: MarkedAsPickedUp}}
+
+	_, err := psm.Fire(context.TODO(), payload, Return)
+	assert.Nil(t, err)
+	_, err = psm.Fire(context.TODO(), payload, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, []plinko.Trigger{Return, Cancel}, payload.deferred)
+
+	// Return is still deferred by Delivered, so Cancel is replayed in its place
+	pr, err := psm.Fire(context.TODO(), payload, Deliver)
+	assert.Nil(t, err)
+	assert.Equal(t, Canceled, pr.GetState())
+	assert.Equal(t, []plinko.Trigger{Return}, payload.deferred)
+}
+
+func TestFireDeferWithoutStore(t *testing.T) {
+	psm := createDeferringDefinition().Compile().StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: MarkedAsPickedUp}, RequestRefund)
+
+	var te *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, RequestRefund, te.Trigger)
+}
+
+func TestDeferredTriggerNeverAccepted(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(MarkedAsPickedUp).
+		Defer(Return).
+		Permit(Deliver, Delivered)
+
+	p.Configure(Delivered).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled)
+
+	co := p.Compile()
+
+	found := false
+	for _, m := range co.Messages {
+		if m.Message == "State 'MarkedAsPickedup' defers Trigger 'Return', but no state reachable from it permits the trigger." {
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestDeferAndPermitPanics(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	assert.Panics(t, func() {
+		p.Configure(Created).
+			Permit(Open, Opened).
+			Defer(Open)
+	})
+
+	assert.Panics(t, func() {
+		p.Configure(Opened).
+			Defer(Open).
+			Permit(Open, Opened)
+	})
+}
@@ -0,0 +1,132 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"sort"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/internal/composition"
+)
+
+// Describe returns a model of the definition that can be inspected without reaching into its
+// internals.  The model is a copy, so changing it does not affect the state machine.
+func (psm plinkoStateMachine) Describe() plinko.Description {
+	d := plinko.Description{
+		Hooks: describeOperations(psm.hooksOf()),
+	}
+
+	for _, sd := range psm.pd.Abs.StateDefinitions {
+		d.States = append(d.States, psm.describeState(sd))
+	}
+
+	for _, sed := range psm.pd.SideEffects {
+		d.SideEffects = append(d.SideEffects, plinko.SideEffectDescription{
+			Name:   nameOf(sed.SideEffect),
+			Filter: sed.Filter,
+			Config: plinko.SideEffectConfig{
+				Sources:      append([]plinko.State(nil), sed.Config.Sources...),
+				Destinations: append([]plinko.State(nil), sed.Config.Destinations...),
+				Triggers:     append([]plinko.Trigger(nil), sed.Config.Triggers...),
+			},
+		})
+	}
+
+	return d
+}
+
+func (psm plinkoStateMachine) describeState(sd *InternalStateDefinition) plinko.StateDescription {
+	d := plinko.StateDescription{
+		State:      sd.State,
+		Config:     copyStateConfig(sd.info),
+		Operations: describeOperations(psm.callbacksOf(sd)),
+	}
+
+	for _, td := range sd.Triggers {
+		cfg := td.Config
+		cfg.Tags = append([]string(nil), cfg.Tags...)
+		cfg.Metadata = copyMetadata(cfg.Metadata)
+
+		guard := cfg.Guard
+		if guard == "" && td.Predicate != nil {
+			guard = nameOf(td.Predicate)
+		}
+
+		d.Triggers = append(d.Triggers, plinko.TriggerDescription{
+			Trigger:     td.Name,
+			Destination: td.DestinationState,
+			Guard:       guard,
+			Config:      cfg,
+		})
+	}
+
+	sort.Slice(d.Triggers, func(i, j int) bool {
+		return d.Triggers[i].Trigger < d.Triggers[j].Trigger
+	})
+
+	for trigger := range sd.Deferred {
+		d.Deferred = append(d.Deferred, trigger)
+	}
+
+	sort.Slice(d.Deferred, func(i, j int) bool {
+		return d.Deferred[i] < d.Deferred[j]
+	})
+
+	return d
+}
+
+func describeOperations(cd *composition.CallbackDefinitions) plinko.OperationChains {
+	chains := plinko.OperationChains{}
+	if cd == nil {
+		return chains
+	}
+
+	for _, fn := range cd.OnEntryFn {
+		chains.Entry = append(chains.Entry, copyOperation(fn.Config))
+	}
+
+	for _, fn := range cd.OnExitFn {
+		chains.Exit = append(chains.Exit, copyOperation(fn.Config))
+	}
+
+	for _, fn := range cd.OnErrorFn {
+		chains.Error = append(chains.Error, copyOperation(fn.Config))
+	}
+
+	return chains
+}
+
+// copyStateConfig copies the configuration of a state, so changes made by the caller do not
+// reach the definition.
+func copyStateConfig(info plinko.StateConfig) plinko.StateConfig {
+	info.Tags = append([]string(nil), info.Tags...)
+	info.Metadata = copyMetadata(info.Metadata)
+	info.Invariants = append([]plinko.Invariant(nil), info.Invariants...)
+
+	return info
+}
+
+func copyOperation(cfg plinko.OperationConfig) plinko.OperationConfig {
+	cfg.Tags = append([]string(nil), cfg.Tags...)
+	cfg.Before = append([]string(nil), cfg.Before...)
+	cfg.After = append([]string(nil), cfg.After...)
+
+	return cfg
+}
+
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+
+	c := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		c[k] = v
+	}
+
+	return c
+}
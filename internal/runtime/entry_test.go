@@ -0,0 +1,126 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFireWithOperationsFromAndTo(t *testing.T) {
+	var ran []string
+
+	record := func(name string) plinko.Operation {
+		return func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			ran = append(ran, name)
+			return p, nil
+		}
+	}
+
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		OnExitTo(Canceled, record("exit to canceled")).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntryFrom(Canceled, record("restore reservation")).
+		OnEntryIf(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) error {
+			if p.(*testPayload).condition {
+				return nil
+			}
+
+			return errors.New("condition not met")
+		}, record("entry if")).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		Permit(Open, Opened)
+
+	co := p.Compile()
+	assert.Empty(t, co.Messages)
+
+	psm := co.StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Nil(t, err)
+	assert.Empty(t, ran)
+
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Created}, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"exit to canceled"}, ran)
+
+	ran = nil
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Canceled, condition: true}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"restore reservation", "entry if"}, ran)
+}
+
+func TestFireWithOperationFromCompositeSource(t *testing.T) {
+	var ran []plinko.State
+
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, InProgress).
+		Permit(Cancel, Canceled)
+
+	p.Configure(InProgress)
+
+	p.Configure(Picking, stateOptions(substateOf(InProgress), initial)...).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		OnEntryFrom(InProgress, func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			ran = append(ran, t.GetSource())
+			return p, nil
+		}).
+		Permit(Open, Created)
+
+	co := p.Compile()
+	assert.Empty(t, co.Messages)
+
+	psm := co.StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: Picking}, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, []plinko.State{Picking}, ran)
+
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Created}, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, []plinko.State{Picking}, ran)
+}
+
+func TestCompileWithUnreachableEntrySource(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntryFrom(Created, TransitionFn(false)).
+		OnEntryFrom(Canceled, TransitionFn(false)).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		Permit(Open, Opened)
+
+	p.Configure(Returned).
+		Permit(Open, Created).
+		OnEntryFrom(Opened, TransitionFn(false))
+
+	co := p.Compile()
+
+	assert.Equal(t, []plinko.CompilerMessage{
+		{CompileMessage: plinko.CompileWarning, Message: "State 'Returned' declares an entry operation from State 'Opened', but that state has no transition into it."},
+	}, co.Messages)
+}
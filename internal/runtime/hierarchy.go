@@ -0,0 +1,197 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+// lineage returns the state definition followed by its ancestors, innermost first.  The walk is
+// bounded by the number of states so a cyclic definition, reported by Compile, cannot loop forever.
+func (pd PlinkoDefinition) lineage(sd *InternalStateDefinition) []*InternalStateDefinition {
+	var states []*InternalStateDefinition
+
+	for sd != nil && len(states) <= len(*pd.States) {
+		states = append(states, sd)
+
+		if sd.info.Parent == "" {
+			break
+		}
+
+		sd = (*pd.States)[sd.info.Parent]
+	}
+
+	return states
+}
+
+// isDescendant reports if state is nested, at any depth, within the ancestor state.
+func (pd PlinkoDefinition) isDescendant(state, ancestor plinko.State) bool {
+	for _, sd := range pd.lineage((*pd.States)[state]) {
+		if sd.State != state && sd.State == ancestor {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWithin reports if the state is the ancestor or is nested within it, walking the states
+// declared so far.  It serves operations, which hold the syntax of the definition rather than
+// the definition itself.
+func (abs *AbstractSyntax) isWithin(state, ancestor plinko.State) bool {
+	for i := 0; i <= len(abs.StateDefinitions) && state != ""; i++ {
+		if state == ancestor {
+			return true
+		}
+
+		state = abs.parentOf(state)
+	}
+
+	return false
+}
+
+func (abs *AbstractSyntax) parentOf(state plinko.State) plinko.State {
+	for _, sd := range abs.StateDefinitions {
+		if sd.State == state {
+			return sd.info.Parent
+		}
+	}
+
+	return ""
+}
+
+func (pd PlinkoDefinition) hasSubstates(state plinko.State) bool {
+	for _, sd := range pd.Abs.StateDefinitions {
+		if sd.info.Parent == state {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveTrigger finds the trigger on the state or the nearest ancestor that declares it.  The
+// nearest state either permits the trigger, returning its definition, or defers it.
+func (pd PlinkoDefinition) resolveTrigger(sd *InternalStateDefinition, trigger plinko.Trigger) (*TriggerDefinition, bool) {
+	for _, s := range pd.lineage(sd) {
+		if td, ok := s.Triggers[trigger]; ok {
+			return td, false
+		}
+
+		if s.Deferred[trigger] {
+			return nil, true
+		}
+	}
+
+	return nil, false
+}
+
+// transitionPath returns the states exited, innermost first, and the states entered, outermost
+// first, when moving between the two states.  Both lists stop short of the innermost state that
+// strictly contains source and destination, so a transition to or from an enclosing state exits
+// and re-enters it.
+func (pd PlinkoDefinition) transitionPath(source, destination *InternalStateDefinition) (exiting, entering []*InternalStateDefinition) {
+	sourceLineage := pd.lineage(source)
+	destinationLineage := pd.lineage(destination)
+
+	destinationAncestors := map[*InternalStateDefinition]bool{}
+	for _, sd := range destinationLineage[1:] {
+		destinationAncestors[sd] = true
+	}
+
+	var common *InternalStateDefinition
+	for _, sd := range sourceLineage[1:] {
+		if destinationAncestors[sd] {
+			common = sd
+			break
+		}
+	}
+
+	for _, sd := range sourceLineage {
+		if sd == common {
+			break
+		}
+		exiting = append(exiting, sd)
+	}
+
+	for _, sd := range destinationLineage {
+		if sd == common {
+			break
+		}
+		entering = append([]*InternalStateDefinition{sd}, entering...)
+	}
+
+	return exiting, entering
+}
+
+func (psm plinkoStateMachine) historyStore(payload plinko.Payload) plinko.HistoryStore {
+	if store, ok := payload.(plinko.HistoryStore); ok {
+		return store
+	}
+
+	return psm.pd.Config.HistoryStore
+}
+
+// resolveDestination returns the state the trigger lands in.  For history transitions this is the
+// substate recorded when the payload last left the composite state, or the composite state itself
+// when nothing has been recorded yet.
+func (psm plinkoStateMachine) resolveDestination(ctx context.Context, payload plinko.Payload, td *TriggerDefinition) (plinko.State, error) {
+	if td.Config.History == plinko.NoHistory {
+		return td.DestinationState, nil
+	}
+
+	store := psm.historyStore(payload)
+	if store == nil {
+		return td.DestinationState, plinkoerror.CreatePlinkoTriggerError(td.Name, fmt.Sprintf("Trigger '%s' transitions to the history of state %s, but neither the payload nor the definition provide a HistoryStore", td.Name, td.DestinationState))
+	}
+
+	last, ok, err := store.GetHistory(ctx, payload, td.DestinationState)
+	if err != nil {
+		return td.DestinationState, err
+	}
+
+	if !ok || !psm.pd.isDescendant(last, td.DestinationState) {
+		return td.DestinationState, nil
+	}
+
+	if td.Config.History == plinko.DeepHistory {
+		return last, nil
+	}
+
+	for _, sd := range psm.pd.lineage((*psm.pd.States)[last]) {
+		if sd.info.Parent == td.DestinationState {
+			return sd.State, nil
+		}
+	}
+
+	return td.DestinationState, nil
+}
+
+// recordHistory remembers the source state for every composite state being exited that is the
+// target of a history transition.
+func (psm plinkoStateMachine) recordHistory(ctx context.Context, payload plinko.Payload, exiting []*InternalStateDefinition, source plinko.State) error {
+	store := psm.historyStore(payload)
+	if store == nil {
+		return nil
+	}
+
+	for _, sd := range exiting {
+		if sd.State == source || !psm.historyTargets[sd.State] {
+			continue
+		}
+
+		if err := store.SetHistory(ctx, payload, sd.State, source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
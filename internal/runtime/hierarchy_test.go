@@ -0,0 +1,215 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+const InProgress plinko.State = "InProgress"
+const Shopping plinko.State = "Shopping"
+const Picking plinko.State = "Picking"
+const Packing plinko.State = "Packing"
+const OnHold plinko.State = "OnHold"
+
+const Hold plinko.Trigger = "Hold"
+const Resume plinko.Trigger = "Resume"
+const ResumeDeep plinko.Trigger = "ResumeDeep"
+const Pack plinko.Trigger = "Pack"
+
+type historyPayload struct {
+	testPayload
+	history map[plinko.State]plinko.State
+}
+
+func (p *historyPayload) GetHistory(_ context.Context, _ plinko.Payload, composite plinko.State) (plinko.State, bool, error) {
+	state, ok := p.history[composite]
+	return state, ok, nil
+}
+
+func (p *historyPayload) SetHistory(_ context.Context, _ plinko.Payload, composite plinko.State, state plinko.State) error {
+	p.history[composite] = state
+	return nil
+}
+
+func substateOf(parent plinko.State) plinko.StateOption {
+	return func(c *plinko.StateConfig) {
+		c.Parent = parent
+	}
+}
+
+func recordingFn(calls *[]string, name string) plinko.Operation {
+	return func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+		*calls = append(*calls, name)
+
+		if tp, ok := p.(*historyPayload); ok {
+			tp.state = t.GetDestination()
+		}
+
+		return p, nil
+	}
+}
+
+func createHierarchicalDefinition(calls *[]string) plinko.PlinkoDefinition {
+	p := createPlinkoDefinition()
+
+	p.Configure(InProgress).
+		OnEntry(recordingFn(calls, "enter InProgress")).
+		OnExit(recordingFn(calls, "exit InProgress")).
+		Permit(Hold, OnHold)
+
+	p.Configure(Shopping, substateOf(InProgress)).
+		OnEntry(recordingFn(calls, "enter Shopping")).
+		OnExit(recordingFn(calls, "exit Shopping"))
+
+	p.Configure(Picking, substateOf(Shopping)).
+		OnEntry(recordingFn(calls, "enter Picking")).
+		OnExit(recordingFn(calls, "exit Picking")).
+		Permit(Pack, Packing)
+
+	p.Configure(Packing, substateOf(InProgress)).
+		OnEntry(recordingFn(calls, "enter Packing")).
+		OnExit(recordingFn(calls, "exit Packing"))
+
+	p.Configure(OnHold).
+		OnEntry(recordingFn(calls, "enter OnHold")).
+		PermitToHistory(Resume, InProgress).
+		PermitToDeepHistory(ResumeDeep, InProgress)
+
+	return p
+}
+
+func TestHierarchicalTransitions(t *testing.T) {
+	var calls []string
+	co := createHierarchicalDefinition(&calls).Compile()
+	assert.Equal(t, 0, len(co.Messages))
+
+	psm := co.StateMachine
+	payload := &historyPayload{testPayload: testPayload{state: Picking}, history: map[plinko.State]plinko.State{}}
+
+	triggers, err := psm.EnumerateActiveTriggers(payload)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []plinko.Trigger{Pack, Hold}, triggers)
+
+	_, err = psm.Fire(context.TODO(), payload, Pack)
+	assert.Nil(t, err)
+	assert.Equal(t, Packing, payload.GetState())
+	assert.Equal(t, []string{"exit Picking", "exit Shopping", "enter Packing"}, calls)
+
+	// the Hold trigger is inherited from the composite state
+	calls = nil
+	assert.Nil(t, psm.CanFire(context.TODO(), payload, Hold))
+	_, err = psm.Fire(context.TODO(), payload, Hold)
+	assert.Nil(t, err)
+	assert.Equal(t, OnHold, payload.GetState())
+	assert.Equal(t, []string{"exit Packing", "exit InProgress", "enter OnHold"}, calls)
+	assert.Equal(t, Packing, payload.history[InProgress])
+}
+
+func TestShallowAndDeepHistory(t *testing.T) {
+	var calls []string
+	psm := createHierarchicalDefinition(&calls).Compile().StateMachine
+
+	payload := &historyPayload{testPayload: testPayload{state: Picking}, history: map[plinko.State]plinko.State{}}
+	_, err := psm.Fire(context.TODO(), payload, Hold)
+	assert.Nil(t, err)
+	assert.Equal(t, Picking, payload.history[InProgress])
+
+	calls = nil
+	_, err = psm.Fire(context.TODO(), payload, Resume)
+	assert.Nil(t, err)
+	assert.Equal(t, Shopping, payload.GetState())
+	assert.Equal(t, []string{"enter InProgress", "enter Shopping"}, calls)
+
+	payload = &historyPayload{testPayload: testPayload{state: Picking}, history: map[plinko.State]plinko.State{}}
+	_, err = psm.Fire(context.TODO(), payload, Hold)
+	assert.Nil(t, err)
+
+	calls = nil
+	_, err = psm.Fire(context.TODO(), payload, ResumeDeep)
+	assert.Nil(t, err)
+	assert.Equal(t, Picking, payload.GetState())
+	assert.Equal(t, []string{"enter InProgress", "enter Shopping", "enter Picking"}, calls)
+}
+
+func TestHistoryWithoutRecord(t *testing.T) {
+	var calls []string
+	psm := createHierarchicalDefinition(&calls).Compile().StateMachine
+
+	payload := &historyPayload{testPayload: testPayload{state: OnHold}, history: map[plinko.State]plinko.State{}}
+	_, err := psm.Fire(context.TODO(), payload, Resume)
+	assert.Nil(t, err)
+	assert.Equal(t, InProgress, payload.GetState())
+}
+
+func TestHistoryWithoutStore(t *testing.T) {
+	var calls []string
+	psm := createHierarchicalDefinition(&calls).Compile().StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: OnHold}, Resume)
+
+	var te *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, Resume, te.Trigger)
+}
+
+type failingHistoryStore struct{}
+
+func (failingHistoryStore) GetHistory(_ context.Context, _ plinko.Payload, _ plinko.State) (plinko.State, bool, error) {
+	return "", false, errors.New("history unavailable")
+}
+
+func (failingHistoryStore) SetHistory(_ context.Context, _ plinko.Payload, _ plinko.State, _ plinko.State) error {
+	return errors.New("history unavailable")
+}
+
+func TestHistoryWithDefinitionStore(t *testing.T) {
+	var calls []string
+	p := createHierarchicalDefinition(&calls)
+	p.(*PlinkoDefinition).Config.HistoryStore = failingHistoryStore{}
+	psm := p.Compile().StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: OnHold}, Resume)
+	assert.EqualError(t, err, "history unavailable")
+
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Picking}, Hold)
+	assert.EqualError(t, err, "history unavailable")
+}
+
+func TestHierarchyCompileErrors(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created, substateOf("Missing")).
+		Permit(Open, Opened)
+
+	p.Configure(Opened, substateOf(Claimed)).
+		PermitToHistory(Cancel, Canceled)
+
+	p.Configure(Claimed, substateOf(Opened))
+
+	p.Configure(Canceled)
+
+	co := p.Compile()
+
+	var messages []string
+	for _, m := range co.Messages {
+		if m.CompileMessage == plinko.CompileError {
+			messages = append(messages, m.Message)
+		}
+	}
+
+	assert.Contains(t, messages, "State 'Missing' undefined: State 'Created' declares it as its parent state.")
+	assert.Contains(t, messages, fmt.Sprintf("State '%s' is nested within itself through its parent states.", Opened))
+	assert.Contains(t, messages, "State 'Canceled' has no substates: Trigger 'Cancel' declares a history transition to this state.")
+}
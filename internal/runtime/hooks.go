@@ -0,0 +1,79 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/internal/sideeffects"
+)
+
+// executeExit runs the global exit operations once per transition, together with the exit
+// operations of the states being left.  A failing global operation is handled by the error
+// operations of the source state.
+func (psm plinkoStateMachine) executeExit(ctx context.Context, states []*InternalStateDefinition, payload plinko.Payload, td *sideeffects.TransitionDef) (plinko.Payload, *InternalStateDefinition, error) {
+	var err error
+
+	if psm.pd.Config.GlobalHookOrder == plinko.GlobalHooksAfter {
+		var failedState *InternalStateDefinition
+		if payload, failedState, err = psm.executeExitChains(ctx, states, payload, td); err != nil {
+			return payload, failedState, err
+		}
+
+		payload, err = psm.hooksOf().ExecuteExitChain(ctx, payload, td)
+		return payload, failedState, err
+	}
+
+	if payload, err = psm.hooksOf().ExecuteExitChain(ctx, payload, td); err != nil {
+		return payload, states[0], err
+	}
+
+	return psm.executeExitChains(ctx, states, payload, td)
+}
+
+// executeEntry runs the global entry operations once per transition, together with the entry
+// operations of the states being entered.  A failing global operation is handled by the error
+// operations of the destination state.
+func (psm plinkoStateMachine) executeEntry(ctx context.Context, states []*InternalStateDefinition, payload plinko.Payload, td *sideeffects.TransitionDef) (plinko.Payload, *InternalStateDefinition, error) {
+	var err error
+
+	if psm.pd.Config.GlobalHookOrder == plinko.GlobalHooksAfter {
+		var failedState *InternalStateDefinition
+		if payload, failedState, err = psm.executeEntryChains(ctx, states, payload, td); err != nil {
+			return payload, failedState, err
+		}
+
+		payload, err = psm.hooksOf().ExecuteEntryChain(ctx, payload, td)
+		return payload, failedState, err
+	}
+
+	if payload, err = psm.hooksOf().ExecuteEntryChain(ctx, payload, td); err != nil {
+		return payload, states[len(states)-1], err
+	}
+
+	return psm.executeEntryChains(ctx, states, payload, td)
+}
+
+// executeError runs the global error operations together with the error operations of the
+// state whose operation failed.  The error returned by the first chain is passed to the second.
+func (psm plinkoStateMachine) executeError(ctx context.Context, failedState *InternalStateDefinition, payload plinko.Payload, td *sideeffects.TransitionDef, err error, elapsedMilliseconds int64) (plinko.Payload, *sideeffects.TransitionDef, error) {
+	chains := []func(context.Context, plinko.Payload, *sideeffects.TransitionDef, error, int64) (plinko.Payload, *sideeffects.TransitionDef, error){
+		psm.hooksOf().ExecuteErrorChain,
+		psm.callbacksOf(failedState).ExecuteErrorChain,
+	}
+
+	if psm.pd.Config.GlobalHookOrder == plinko.GlobalHooksAfter {
+		chains[0], chains[1] = chains[1], chains[0]
+	}
+
+	for _, chain := range chains {
+		payload, td, err = chain(ctx, payload, td, err, elapsedMilliseconds)
+	}
+
+	return payload, td, err
+}
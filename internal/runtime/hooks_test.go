@@ -0,0 +1,106 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+func createHookedDefinition(order plinko.HookOrder, ran *[]string) plinko.PlinkoDefinition {
+	record := func(name string, err error) plinko.Operation {
+		return func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			*ran = append(*ran, name)
+			return p, err
+		}
+	}
+
+	recordError := func(name string) plinko.ErrorOperation {
+		return func(_ context.Context, p plinko.Payload, _ plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+			*ran = append(*ran, name)
+			return p, err
+		}
+	}
+
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.GlobalHookOrder = order
+
+	p.OnAnyExit(record("any exit", nil)).
+		OnAnyEntry(record("any entry", nil)).
+		OnAnyError(recordError("any error"))
+
+	p.Configure(Created).
+		OnExit(record("exit", nil)).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntry(record("entry", nil))
+
+	p.Configure(Canceled).
+		OnEntry(record("failing entry", errors.New("cannot cancel"))).
+		OnError(recordError("error"))
+
+	return p
+}
+
+func TestFireWithGlobalHooksBefore(t *testing.T) {
+	var ran []string
+
+	psm := createHookedDefinition(plinko.GlobalHooksBefore, &ran).Compile().StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"any exit", "exit", "any entry", "entry"}, ran)
+
+	ran = nil
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Created}, Cancel)
+	assert.Equal(t, "cannot cancel", err.Error())
+	assert.Equal(t, []string{"any exit", "exit", "any entry", "failing entry", "any error", "error"}, ran)
+}
+
+func TestFireWithGlobalHooksAfter(t *testing.T) {
+	var ran []string
+
+	psm := createHookedDefinition(plinko.GlobalHooksAfter, &ran).Compile().StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"exit", "any exit", "entry", "any entry"}, ran)
+
+	ran = nil
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Created}, Cancel)
+	assert.Equal(t, "cannot cancel", err.Error())
+	assert.Equal(t, []string{"exit", "any exit", "failing entry", "error", "any error"}, ran)
+}
+
+func TestFireWithFailingGlobalHook(t *testing.T) {
+	var handledBy plinko.State
+
+	p := createPlinkoDefinition()
+
+	p.OnAnyEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+		return p, errors.New("audit unavailable")
+	})
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnError(func(_ context.Context, p plinko.Payload, t plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+			handledBy = t.GetDestination()
+			return p, err
+		})
+
+	_, err := p.Compile().StateMachine.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Equal(t, "audit unavailable", err.Error())
+	assert.Equal(t, Opened, handledBy)
+}
@@ -0,0 +1,70 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+var errNoIdempotencyStore = errors.New("FireIdempotent requires an IdempotencyStore to be configured on the definition")
+
+// FireIdempotent fires the trigger once per key.  The outcome of the first call, including its
+// error, is recorded in the configured store and returned to every later call with the same key
+// without running the transition again.  Retryable errors and errors of a canceled context are
+// not recorded: the key is released, so a later call fires the trigger again.  The key is
+// reserved before the trigger fires, so a concurrent call with the same key waits for the
+// outcome rather than firing again.  Payloads
+// implementing plinko.Cloneable are copied as they are recorded and returned, so later changes
+// to the payload do not alter the recorded outcome.
+func (psm plinkoStateMachine) FireIdempotent(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger, key string) (plinko.Payload, error) {
+	store := psm.pd.Config.IdempotencyStore
+	if store == nil {
+		return payload, errNoIdempotencyStore
+	}
+
+	err := psm.withEntityLock(ctx, entityID(payload), func(ctx context.Context) error {
+		result, done, err := store.Reserve(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			payload = copyPayload(result.Payload)
+			return result.Err
+		}
+
+		payload, err = psm.fire(ctx, payload, trigger)
+
+		if plinkoerror.IsRetryable(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			// the context may be done already, and the error of the fire is reported rather than
+			// a failure to release the key
+			_ = store.Release(context.Background(), key)
+			return err
+		}
+
+		if putErr := store.Put(ctx, key, plinko.IdempotentResult{Payload: copyPayload(payload), Err: err}); putErr != nil && err == nil {
+			return putErr
+		}
+
+		return err
+	})
+
+	return payload, err
+}
+
+// copyPayload returns a copy of payloads that can be cloned, and the payload itself otherwise.
+func copyPayload(payload plinko.Payload) plinko.Payload {
+	if cloneable, ok := payload.(plinko.Cloneable); ok {
+		return cloneable.Clone()
+	}
+
+	return payload
+}
@@ -0,0 +1,201 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/idempotency/memory"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFireIdempotent(t *testing.T) {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.IdempotencyStore = memory.NewStore(time.Minute)
+
+	entries := 0
+	p.Configure(Created).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntry(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			entries++
+			p.(*testPayload).state = t.GetDestination()
+			return p, nil
+		}).
+		PermitReentry(Open)
+
+	p.Configure(Canceled).
+		OnEntry(TransitionFn(true))
+
+	psm := p.Compile().StateMachine
+
+	payload := &testPayload{state: Created}
+
+	pr, err := psm.FireIdempotent(context.TODO(), payload, Open, "open-1")
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, pr.GetState())
+	assert.Equal(t, 1, entries)
+
+	pr, err = psm.FireIdempotent(context.TODO(), payload, Open, "open-1")
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, pr.GetState())
+	assert.Equal(t, 1, entries)
+
+	_, err = psm.FireIdempotent(context.TODO(), payload, Open, "open-2")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, entries)
+
+	// the error of the first call is returned again
+	payload.state = Created
+	_, err = psm.FireIdempotent(context.TODO(), payload, Cancel, "cancel-1")
+	assert.EqualError(t, err, "error")
+
+	payload.state = Created
+	_, err = psm.FireIdempotent(context.TODO(), payload, Open, "cancel-1")
+	assert.EqualError(t, err, "error")
+	assert.Equal(t, Created, payload.GetState())
+}
+
+func TestFireIdempotentReleasesRetryableErrors(t *testing.T) {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.IdempotencyStore = memory.NewStore(time.Minute)
+
+	var failures []error
+	entries := 0
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			entries++
+			if entries <= len(failures) {
+				return p, failures[entries-1]
+			}
+
+			p.(*testPayload).state = t.GetDestination()
+			return p, nil
+		})
+
+	psm := p.Compile().StateMachine
+
+	failures = []error{plinkoerror.Retryable(errors.New("inventory unavailable")), context.Canceled, context.DeadlineExceeded}
+	payload := &testPayload{state: Created}
+
+	// none of the failures is recorded, so every delivery of the key fires again
+	for _, failure := range failures {
+		_, err := psm.FireIdempotent(context.TODO(), payload, Open, "open-1")
+		assert.True(t, errors.Is(err, failure))
+		assert.Equal(t, Created, payload.GetState())
+	}
+
+	pr, err := psm.FireIdempotent(context.TODO(), payload, Open, "open-1")
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, pr.GetState())
+	assert.Equal(t, 4, entries)
+
+	_, err = psm.FireIdempotent(context.TODO(), payload, Open, "open-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, entries)
+}
+
+func TestFireIdempotentConcurrent(t *testing.T) {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.IdempotencyStore = memory.NewStore(time.Minute)
+
+	var mu sync.Mutex
+	entries := 0
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			mu.Lock()
+			entries++
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+			p.(*testPayload).state = t.GetDestination()
+			return p, nil
+		})
+
+	psm := p.Compile().StateMachine
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pr, err := psm.FireIdempotent(context.TODO(), &testPayload{state: Created}, Open, "open-1")
+			assert.Nil(t, err)
+			assert.Equal(t, Opened, pr.GetState())
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, entries)
+}
+
+func TestFireIdempotentRecordsCopy(t *testing.T) {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.IdempotencyStore = memory.NewStore(time.Minute)
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(TransitionFn(false))
+
+	psm := p.Compile().StateMachine
+
+	pr, err := psm.FireIdempotent(context.TODO(), &testPayload{state: Created}, Open, "open-1")
+	assert.Nil(t, err)
+	pr.(*testPayload).state = Canceled
+
+	pr, err = psm.FireIdempotent(context.TODO(), &testPayload{state: Created}, Open, "open-1")
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, pr.GetState())
+}
+
+type failingIdempotencyStore struct{}
+
+func (failingIdempotencyStore) Reserve(context.Context, string) (plinko.IdempotentResult, bool, error) {
+	return plinko.IdempotentResult{}, false, errors.New("store unavailable")
+}
+
+func (failingIdempotencyStore) Put(context.Context, string, plinko.IdempotentResult) error {
+	return nil
+}
+
+func (failingIdempotencyStore) Release(context.Context, string) error {
+	return nil
+}
+
+func TestFireIdempotentStoreErrors(t *testing.T) {
+	p := createPlinkoDefinition()
+	p.Configure(Created).Permit(Open, Opened)
+	p.Configure(Opened)
+
+	payload := &testPayload{state: Created}
+
+	_, err := p.Compile().StateMachine.FireIdempotent(context.TODO(), payload, Open, "open-1")
+	assert.Equal(t, errNoIdempotencyStore, err)
+
+	p.(*PlinkoDefinition).Config.IdempotencyStore = failingIdempotencyStore{}
+
+	_, err = p.Compile().StateMachine.FireIdempotent(context.TODO(), payload, Open, "open-1")
+	assert.EqualError(t, err, "store unavailable")
+	assert.Equal(t, Created, payload.GetState())
+}
@@ -19,13 +19,32 @@ import (
 )
 
 type plinkoStateMachine struct {
-	pd PlinkoDefinition
+	pd          PlinkoDefinition
+	sideEffects *sideeffects.Index
+	fire        plinko.FireFunc
+	canFire     plinko.FireFunc
+	// step runs a single transition, the fired trigger or one queued by its operations,
+	// through the middleware.
+	step plinko.FireFunc
+
+	historyTargets map[plinko.State]bool
+	regions        map[plinko.State][]*InternalStateDefinition
+	initialStates  map[plinko.State]*InternalStateDefinition
+	joins          map[plinko.State]plinko.Trigger
+
+	// callbacks and hooks hold the operations of the states and the definition, in the order
+	// resolved at compile time.
+	callbacks map[plinko.State]*composition.CallbackDefinitions
+	hooks     *composition.CallbackDefinitions
 }
 
 type InternalStateDefinition struct {
 	State    plinko.State
 	Triggers map[plinko.Trigger]*TriggerDefinition
-	info     plinko.StateConfig
+	Deferred map[plinko.Trigger]bool
+	// EntrySources holds the source states named by OnEntryFrom, validated at compile time.
+	EntrySources map[plinko.State]bool
+	info         plinko.StateConfig
 
 	Callbacks *composition.CallbackDefinitions
 
@@ -119,6 +138,20 @@ func (sd InternalStateDefinition) OnExit(exitFn plinko.Operation, opts ...plinko
 	return sd
 }
 
+// OnEntryWhen adds an entry operation that only runs when the boolean guard holds.
+func (sd InternalStateDefinition) OnEntryWhen(predicate plinko.TriggerPredicate, entryFn plinko.Operation, opts ...plinko.OperationOption) plinko.StateDefinition {
+	sd.Callbacks.AddEntry(sd.whenPredicate(predicate, "an entry operation", nil), entryFn, newOperationConfig(entryFn, opts...))
+
+	return sd
+}
+
+// OnExitWhen adds an exit operation that only runs when the boolean guard holds.
+func (sd InternalStateDefinition) OnExitWhen(predicate plinko.TriggerPredicate, exitFn plinko.Operation, opts ...plinko.OperationOption) plinko.StateDefinition {
+	sd.Callbacks.AddExit(sd.whenPredicate(predicate, "an exit operation", nil), exitFn, newOperationConfig(exitFn, opts...))
+
+	return sd
+}
+
 func (sd InternalStateDefinition) OnTriggerEntry(trigger plinko.Trigger, entryFn plinko.Operation, opts ...plinko.OperationOption) plinko.StateDefinition {
 	sd.Callbacks.AddEntry(func(_ context.Context, _ plinko.Payload, t plinko.TransitionInfo) error {
 		if t.GetTrigger() == trigger {
@@ -144,26 +177,172 @@ func (sd InternalStateDefinition) OnTriggerExit(trigger plinko.Trigger, exitFn p
 	return sd
 }
 
-func (sd InternalStateDefinition) PermitReentry(trigger plinko.Trigger) plinko.StateDefinition {
-	addPermit(&sd, trigger, sd.State, nil)
+// OnEntryFrom adds an entry operation that only runs when the state is entered from the source
+// state.  A composite source matches transitions from any of its substates.
+func (sd InternalStateDefinition) OnEntryFrom(source plinko.State, entryFn plinko.Operation, opts ...plinko.OperationOption) plinko.StateDefinition {
+	sd.EntrySources[source] = true
+
+	abs := sd.Abs
+	sd.Callbacks.AddEntry(func(_ context.Context, _ plinko.Payload, t plinko.TransitionInfo) error {
+		if abs.isWithin(t.GetSource(), source) {
+			return nil
+		}
+
+		return fmt.Errorf("source '%s' not found for entry", source)
+	}, entryFn, newOperationConfig(entryFn, opts...))
+
+	return sd
+}
+
+// OnExitTo adds an exit operation that only runs when the state is exited to the destination state.
+func (sd InternalStateDefinition) OnExitTo(destination plinko.State, exitFn plinko.Operation, opts ...plinko.OperationOption) plinko.StateDefinition {
+	sd.Callbacks.AddExit(func(_ context.Context, _ plinko.Payload, t plinko.TransitionInfo) error {
+		if t.GetDestination() == destination {
+			return nil
+		}
+
+		return fmt.Errorf("destination '%s' not found for exit", destination)
+	}, exitFn, newOperationConfig(exitFn, opts...))
+
+	return sd
+}
+
+// OnEntryIf adds an entry operation that only runs when the predicate returns no error.
+func (sd InternalStateDefinition) OnEntryIf(predicate plinko.Predicate, entryFn plinko.Operation, opts ...plinko.OperationOption) plinko.StateDefinition {
+	sd.Callbacks.AddEntry(predicate, entryFn, newOperationConfig(entryFn, opts...))
 
 	return sd
 }
 
-func (sd InternalStateDefinition) PermitReentryIf(predicate plinko.Predicate, trigger plinko.Trigger) plinko.StateDefinition {
-	addPermit(&sd, trigger, sd.State, predicate)
+func (sd InternalStateDefinition) Defer(trigger plinko.Trigger) plinko.StateDefinition {
+	if _, ok := sd.Triggers[trigger]; ok {
+		panic(fmt.Sprintf("Trigger: %s - is permitted and cannot be deferred, plinko configuration invalid.", trigger))
+	}
+
+	sd.Deferred[trigger] = true
 
 	return sd
 }
 
-func (sd InternalStateDefinition) Permit(trigger plinko.Trigger, destinationState plinko.State) plinko.StateDefinition {
-	addPermit(&sd, trigger, destinationState, nil)
+func (sd InternalStateDefinition) PermitReentry(trigger plinko.Trigger, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	addPermit(&sd, trigger, sd.State, nil, newTriggerConfig(opts...))
 
 	return sd
 }
 
-func (sd InternalStateDefinition) PermitIf(predicate plinko.Predicate, trigger plinko.Trigger, destinationState plinko.State) plinko.StateDefinition {
-	addPermit(&sd, trigger, destinationState, predicate)
+func (sd InternalStateDefinition) PermitReentryIf(predicate plinko.Predicate, trigger plinko.Trigger, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	addPermit(&sd, trigger, sd.State, predicate, newTriggerConfig(opts...))
+
+	return sd
+}
+
+// PermitReentryWhen permits reentry on the trigger when the boolean guard holds.
+func (sd InternalStateDefinition) PermitReentryWhen(predicate plinko.TriggerPredicate, trigger plinko.Trigger, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	cfg := newTriggerConfig(opts...)
+	addPermit(&sd, trigger, sd.State, sd.whenPredicate(predicate, fmt.Sprintf("Trigger '%s'", trigger), &cfg), cfg)
+
+	return sd
+}
+
+// PermitReentryGuard permits reentry on the trigger when the guard passes.
+func (sd InternalStateDefinition) PermitReentryGuard(guard plinko.Guard, trigger plinko.Trigger, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	cfg := newTriggerConfig(opts...)
+	addPermit(&sd, trigger, sd.State, sd.guardPredicate(guard, trigger, &cfg), cfg)
+
+	return sd
+}
+
+func (sd InternalStateDefinition) Permit(trigger plinko.Trigger, destinationState plinko.State, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	addPermit(&sd, trigger, destinationState, nil, newTriggerConfig(opts...))
+
+	return sd
+}
+
+func (sd InternalStateDefinition) PermitIf(predicate plinko.Predicate, trigger plinko.Trigger, destinationState plinko.State, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	addPermit(&sd, trigger, destinationState, predicate, newTriggerConfig(opts...))
+
+	return sd
+}
+
+// PermitWhen permits the trigger when the boolean guard holds.
+func (sd InternalStateDefinition) PermitWhen(predicate plinko.TriggerPredicate, trigger plinko.Trigger, destinationState plinko.State, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	cfg := newTriggerConfig(opts...)
+	addPermit(&sd, trigger, destinationState, sd.whenPredicate(predicate, fmt.Sprintf("Trigger '%s'", trigger), &cfg), cfg)
+
+	return sd
+}
+
+// PermitGuard permits the trigger when the guard passes, labelling the transition with the
+// description of the guard.
+func (sd InternalStateDefinition) PermitGuard(guard plinko.Guard, trigger plinko.Trigger, destinationState plinko.State, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	cfg := newTriggerConfig(opts...)
+	addPermit(&sd, trigger, destinationState, sd.guardPredicate(guard, trigger, &cfg), cfg)
+
+	return sd
+}
+
+// whenPredicate adapts a boolean guard to a predicate.  When cfg is given, the transition is
+// labelled with the guard's function name.  A nil guard is reported when the definition is compiled.
+func (sd InternalStateDefinition) whenPredicate(predicate plinko.TriggerPredicate, declaration string, cfg *plinko.TriggerConfig) plinko.Predicate {
+	if predicate == nil {
+		sd.nilGuard(declaration)
+		return nil
+	}
+
+	name := nameOf(predicate)
+	if cfg != nil {
+		cfg.Guard = name
+	}
+
+	return func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) error {
+		if predicate(ctx, p, t) {
+			return nil
+		}
+
+		return fmt.Errorf("guard '%s' not satisfied", name)
+	}
+}
+
+// guardPredicate returns the predicate evaluating the guard, and labels the transition with the
+// description of the guard.
+func (sd InternalStateDefinition) guardPredicate(guard plinko.Guard, trigger plinko.Trigger, cfg *plinko.TriggerConfig) plinko.Predicate {
+	if guard == nil {
+		sd.nilGuard(fmt.Sprintf("Trigger '%s'", trigger))
+		return nil
+	}
+
+	cfg.Guard = guard.String()
+
+	return guard.Check
+}
+
+func (sd InternalStateDefinition) nilGuard(declaration string) {
+	sd.Abs.CompilerMessages = append(sd.Abs.CompilerMessages, plinko.CompilerMessage{
+		CompileMessage: plinko.CompileError,
+		Message:        fmt.Sprintf("State '%s' declares %s with a nil guard.", sd.State, declaration),
+	})
+}
+
+func (sd InternalStateDefinition) PermitToHistory(trigger plinko.Trigger, compositeState plinko.State, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	cfg := newTriggerConfig(opts...)
+	cfg.History = plinko.ShallowHistory
+	addPermit(&sd, trigger, compositeState, nil, cfg)
+
+	return sd
+}
+
+func (sd InternalStateDefinition) PermitToDeepHistory(trigger plinko.Trigger, compositeState plinko.State, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	cfg := newTriggerConfig(opts...)
+	cfg.History = plinko.DeepHistory
+	addPermit(&sd, trigger, compositeState, nil, cfg)
+
+	return sd
+}
+
+func (sd InternalStateDefinition) PermitJoin(trigger plinko.Trigger, destinationState plinko.State, opts ...plinko.TriggerOption) plinko.StateDefinition {
+	cfg := newTriggerConfig(opts...)
+	cfg.Join = true
+	addPermit(&sd, trigger, destinationState, nil, cfg)
 
 	return sd
 }
@@ -172,12 +351,21 @@ type AbstractSyntax struct {
 	States             []plinko.State
 	TriggerDefinitions []TriggerDefinition
 	StateDefinitions   []*InternalStateDefinition
+	// CompilerMessages holds the problems found while the definition was declared, reported
+	// when it is compiled.
+	CompilerMessages []plinko.CompilerMessage
 }
 
 type PlinkoDefinition struct {
 	States      *map[plinko.State]*InternalStateDefinition
 	SideEffects []sideeffects.SideEffectDefinition
+	Middleware  []plinko.Middleware
+	Config      plinko.DefinitionConfig
 	Abs         AbstractSyntax
+	// Hooks holds the operations run on every transition, registered with OnAnyEntry, OnAnyExit
+	// and OnAnyError.  It is shared with the state machines compiled from the definition, like the
+	// operations of its states.
+	Hooks *composition.CallbackDefinitions
 }
 
 func findDestinationState(states []plinko.State, searchState plinko.State) bool {
@@ -190,18 +378,64 @@ func findDestinationState(states []plinko.State, searchState plinko.State) bool
 	return false
 }
 
-func (pd *PlinkoDefinition) SideEffect(sideEffect plinko.SideEffect) plinko.PlinkoDefinition {
-	pd.SideEffects = append(pd.SideEffects, sideeffects.SideEffectDefinition{Filter: sideeffects.AllowAllSideEffects, SideEffect: sideEffect})
+func (pd *PlinkoDefinition) SideEffect(sideEffect plinko.SideEffect, opts ...plinko.SideEffectOption) plinko.PlinkoDefinition {
+	pd.SideEffects = append(pd.SideEffects, sideeffects.SideEffectDefinition{Filter: sideeffects.AllowAllSideEffects, SideEffect: sideEffect, Config: newSideEffectConfig(opts...)})
+
+	return pd
+}
+
+func (pd *PlinkoDefinition) FilteredSideEffect(filter plinko.SideEffectFilter, sideEffect plinko.SideEffect, opts ...plinko.SideEffectOption) plinko.PlinkoDefinition {
+	pd.SideEffects = append(pd.SideEffects, sideeffects.SideEffectDefinition{Filter: filter, SideEffect: sideEffect, Config: newSideEffectConfig(opts...)})
+
+	return pd
+}
+
+func (pd *PlinkoDefinition) Use(middleware ...plinko.Middleware) plinko.PlinkoDefinition {
+	pd.Middleware = append(pd.Middleware, middleware...)
+
+	return pd
+}
+
+// hooks returns the operations run on every transition, creating them for a definition that
+// was not created with any.
+func (pd *PlinkoDefinition) hooks() *composition.CallbackDefinitions {
+	if pd.Hooks == nil {
+		pd.Hooks = &composition.CallbackDefinitions{}
+	}
+
+	return pd.Hooks
+}
+
+// OnAnyEntry adds an entry operation that runs on every transition.
+func (pd *PlinkoDefinition) OnAnyEntry(entryFn plinko.Operation, opts ...plinko.OperationOption) plinko.PlinkoDefinition {
+	pd.hooks().AddEntry(nil, entryFn, newOperationConfig(entryFn, opts...))
+
+	return pd
+}
+
+// OnAnyExit adds an exit operation that runs on every transition.
+func (pd *PlinkoDefinition) OnAnyExit(exitFn plinko.Operation, opts ...plinko.OperationOption) plinko.PlinkoDefinition {
+	pd.hooks().AddExit(nil, exitFn, newOperationConfig(exitFn, opts...))
 
 	return pd
 }
 
-func (pd *PlinkoDefinition) FilteredSideEffect(filter plinko.SideEffectFilter, sideEffect plinko.SideEffect) plinko.PlinkoDefinition {
-	pd.SideEffects = append(pd.SideEffects, sideeffects.SideEffectDefinition{Filter: filter, SideEffect: sideEffect})
+// OnAnyError adds an error operation that runs whenever a transition fails.
+func (pd *PlinkoDefinition) OnAnyError(errorFn plinko.ErrorOperation, opts ...plinko.OperationOption) plinko.PlinkoDefinition {
+	pd.hooks().AddError(errorFn, newOperationConfig(errorFn, opts...))
 
 	return pd
 }
 
+// applyMiddleware wraps the fire function so the first registered middleware is the outermost call.
+func applyMiddleware(middleware []plinko.Middleware, fire plinko.FireFunc) plinko.FireFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		fire = middleware[i](fire)
+	}
+
+	return fire
+}
+
 func (pd *PlinkoDefinition) Configure(state plinko.State, opts ...plinko.StateOption) plinko.StateDefinition {
 	if _, ok := (*pd.States)[state]; ok {
 		panic(fmt.Sprintf("State: %s - has already been defined, plinko configuration invalid.", state))
@@ -210,11 +444,13 @@ func (pd *PlinkoDefinition) Configure(state plinko.State, opts ...plinko.StateOp
 	cbd := composition.CallbackDefinitions{}
 
 	sd := InternalStateDefinition{
-		State:     state,
-		Triggers:  make(map[plinko.Trigger]*TriggerDefinition),
-		Abs:       &pd.Abs,
-		Callbacks: &cbd,
-		info:      newStateConfig(state, opts...),
+		State:        state,
+		Triggers:     make(map[plinko.Trigger]*TriggerDefinition),
+		Deferred:     make(map[plinko.Trigger]bool),
+		EntrySources: make(map[plinko.State]bool),
+		Abs:          &pd.Abs,
+		Callbacks:    &cbd,
+		info:         newStateConfig(state, opts...),
 	}
 
 	(*pd.States)[state] = &sd
@@ -229,27 +465,43 @@ type TriggerDefinition struct {
 	Name             plinko.Trigger
 	DestinationState plinko.State
 	Predicate        func(context.Context, plinko.Payload, plinko.TransitionInfo) error
+	Config           plinko.TriggerConfig
 }
 
 type PlinkoDataStructure struct {
 	States map[plinko.State]plinko.StateDefinition
 }
 
-func addPermit(sd *InternalStateDefinition, trigger plinko.Trigger, destination plinko.State, predicate func(context.Context, plinko.Payload, plinko.TransitionInfo) error) {
+func addPermit(sd *InternalStateDefinition, trigger plinko.Trigger, destination plinko.State, predicate func(context.Context, plinko.Payload, plinko.TransitionInfo) error, cfg plinko.TriggerConfig) {
 	if _, ok := sd.Triggers[trigger]; ok {
 		panic(fmt.Sprintf("Trigger: %s - has already been defined, plinko configuration invalid.", trigger))
 	}
 
+	if sd.Deferred[trigger] {
+		panic(fmt.Sprintf("Trigger: %s - is deferred and cannot be permitted, plinko configuration invalid.", trigger))
+	}
+
 	td := TriggerDefinition{
 		Name:             trigger,
 		DestinationState: destination,
 		Predicate:        predicate,
+		Config:           cfg,
 	}
 
 	sd.Triggers[trigger] = &td
 	sd.Abs.TriggerDefinitions = append(sd.Abs.TriggerDefinitions, td)
 }
 
+func newTriggerConfig(opts ...plinko.TriggerOption) plinko.TriggerConfig {
+	c := plinko.TriggerConfig{}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
 func newOperationConfig(op interface{}, opts ...plinko.OperationOption) plinko.OperationConfig {
 	c := plinko.OperationConfig{
 		Name: getFunctionName(op),
@@ -277,3 +529,13 @@ func newStateConfig(state plinko.State, opts ...plinko.StateOption) plinko.State
 
 	return c
 }
+
+func newSideEffectConfig(opts ...plinko.SideEffectOption) plinko.SideEffectConfig {
+	c := plinko.SideEffectConfig{}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
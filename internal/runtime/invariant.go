@@ -0,0 +1,42 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+// Validate checks the payload against the invariants of its current state and of the states
+// that state is nested in.
+func (psm plinkoStateMachine) Validate(ctx context.Context, payload plinko.Payload) error {
+	state := payload.GetState()
+	sd := (*psm.pd.States)[state]
+
+	if sd == nil {
+		return plinkoerror.CreatePlinkoStateError(state, fmt.Sprintf("State '%s' not defined", state))
+	}
+
+	return psm.checkInvariants(ctx, payload, sd)
+}
+
+// checkInvariants returns the first invariant of the state, or of its parent states, that the
+// payload violates.
+func (psm plinkoStateMachine) checkInvariants(ctx context.Context, payload plinko.Payload, sd *InternalStateDefinition) error {
+	for _, s := range psm.pd.lineage(sd) {
+		for _, invariant := range s.info.Invariants {
+			if err := invariant.Check(ctx, payload); err != nil {
+				return plinkoerror.CreatePlinkoInvariantError(s.State, invariant.Name, err, fmt.Sprintf("Invariant '%s' of state '%s' violated: %v", invariant.Name, s.State, err))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func withInvariant(name string, check func(context.Context, plinko.Payload) error) plinko.StateOption {
+	return func(c *plinko.StateConfig) {
+		c.Invariants = append(c.Invariants, plinko.Invariant{Name: name, Check: check})
+	}
+}
+
+func hasCondition(_ context.Context, p plinko.Payload) error {
+	if !p.(*testPayload).condition {
+		return errors.New("condition not set")
+	}
+
+	return nil
+}
+
+func TestFireChecksInvariants(t *testing.T) {
+	var handled error
+
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Claim, Claimed)
+
+	p.Configure(Opened, withInvariant("HasCondition", hasCondition))
+
+	p.Configure(Claimed, substateOf(Opened)).
+		OnEntry(TransitionFn(false)).
+		OnError(func(_ context.Context, p plinko.Payload, _ plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+			handled = err
+			return p, nil
+		})
+
+	psm := p.Compile().StateMachine
+
+	payload := &testPayload{state: Created, condition: true}
+	_, err := psm.Fire(context.TODO(), payload, Claim)
+	assert.Nil(t, err)
+	assert.Nil(t, psm.Validate(context.TODO(), payload))
+
+	payload = &testPayload{state: Created}
+	_, err = psm.Fire(context.TODO(), payload, Claim)
+
+	var pie *plinkoerror.PlinkoInvariantError
+	assert.True(t, errors.As(err, &pie))
+	assert.Equal(t, Opened, pie.State)
+	assert.Equal(t, "HasCondition", pie.Invariant)
+	assert.Equal(t, "Invariant 'HasCondition' of state 'Opened' violated: condition not set", err.Error())
+	assert.Equal(t, err, handled)
+
+	err = psm.Validate(context.TODO(), &testPayload{state: Claimed})
+	assert.True(t, errors.As(err, &pie))
+
+	var pse *plinkoerror.PlinkoStateError
+	err = psm.Validate(context.TODO(), &testPayload{state: "not-a-real-state"})
+	assert.True(t, errors.As(err, &pse))
+}
@@ -0,0 +1,49 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/internal/composition"
+)
+
+// withOperationTrace starts tracing the operations run by a transition when a journal is
+// configured, so they can be recorded with it.
+func (psm plinkoStateMachine) withOperationTrace(ctx context.Context) (context.Context, *composition.OperationTrace) {
+	if psm.pd.Config.Journal == nil {
+		return ctx, nil
+	}
+
+	trace := &composition.OperationTrace{}
+
+	return composition.WithOperationTrace(ctx, trace), trace
+}
+
+// recordJournal records a completed transition to the configured journal.
+func (psm plinkoStateMachine) recordJournal(ctx context.Context, payload plinko.Payload, transitionInfo plinko.TransitionInfo, trace *composition.OperationTrace, start time.Time) error {
+	if psm.pd.Config.Journal == nil {
+		return nil
+	}
+
+	entry := plinko.JournalEntry{
+		Source:      transitionInfo.GetSource(),
+		Destination: transitionInfo.GetDestination(),
+		Trigger:     transitionInfo.GetTrigger(),
+		Timestamp:   start,
+		Elapsed:     time.Since(start),
+		Operations:  trace.Names,
+	}
+
+	if identifiable, ok := payload.(plinko.Identifiable); ok {
+		entry.ID = identifiable.GetID()
+	}
+
+	return psm.pd.Config.Journal.Record(ctx, payload, entry)
+}
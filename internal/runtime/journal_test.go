@@ -0,0 +1,95 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingJournal struct {
+	entries []plinko.JournalEntry
+	err     error
+}
+
+func (j *recordingJournal) Record(_ context.Context, _ plinko.Payload, entry plinko.JournalEntry) error {
+	j.entries = append(j.entries, entry)
+	return j.err
+}
+
+type identifiedPayload struct {
+	testPayload
+	id string
+}
+
+func (p *identifiedPayload) GetID() string {
+	return p.id
+}
+
+func (p *identifiedPayload) Clone() plinko.Payload {
+	c := *p
+	return &c
+}
+
+func SetOpenedState(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+	p.(*identifiedPayload).state = t.GetDestination()
+	return p, nil
+}
+
+func LogExit(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+	return p, nil
+}
+
+func TestFireRecordsJournal(t *testing.T) {
+	journal := &recordingJournal{}
+
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.Journal = journal
+
+	p.Configure(Created).
+		OnExit(LogExit, func(c *plinko.OperationConfig) { c.Name = "LogExit" }).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntry(SetOpenedState)
+
+	p.Configure(Canceled).
+		OnEntry(TransitionFn(true))
+
+	psm := p.Compile().StateMachine
+
+	payload := &identifiedPayload{testPayload: testPayload{state: Created}, id: "order-1"}
+
+	_, err := psm.Fire(context.TODO(), payload, Open)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(journal.entries))
+	entry := journal.entries[0]
+	assert.Equal(t, "order-1", entry.ID)
+	assert.Equal(t, Created, entry.Source)
+	assert.Equal(t, Opened, entry.Destination)
+	assert.Equal(t, Open, entry.Trigger)
+	assert.False(t, entry.Timestamp.IsZero())
+	assert.Equal(t, []string{"LogExit", "SetOpenedState"}, entry.Operations)
+
+	// failed transitions are not journaled
+	payload.state = Created
+	_, err = psm.Fire(context.TODO(), payload, Cancel)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(journal.entries))
+
+	journal.err = errors.New("journal unavailable")
+	payload.state = Created
+	_, err = psm.Fire(context.TODO(), payload, Open)
+	assert.Equal(t, journal.err, err)
+	assert.Equal(t, Opened, payload.GetState())
+}
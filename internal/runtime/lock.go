@@ -0,0 +1,85 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+type heldLockKey struct{}
+
+// heldLock records the locks held by the calls in progress on a context, so a Fire nested
+// within an operation does not wait on a lock its caller holds.
+type heldLock struct {
+	key    string
+	parent *heldLock
+}
+
+func isLockHeld(ctx context.Context, key string) bool {
+	held, _ := ctx.Value(heldLockKey{}).(*heldLock)
+	for ; held != nil; held = held.parent {
+		if held.key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// entityID returns the identifier transitions on the payload are serialized by, which is empty
+// for payloads that do not implement plinko.Identifiable.
+func entityID(payload plinko.Payload) string {
+	if identifiable, ok := payload.(plinko.Identifiable); ok {
+		return identifiable.GetID()
+	}
+
+	return ""
+}
+
+// lockKey returns the key of the lock the entity is held under, which differs from the id
+// for lockers sharing a lock between entities.
+func lockKey(locker plinko.Locker, id string) string {
+	if keyer, ok := locker.(plinko.LockKeyer); ok {
+		return keyer.LockKey(id)
+	}
+
+	return id
+}
+
+// withEntityLock runs fn holding the lock of the entity when a locker is configured.
+func (psm plinkoStateMachine) withEntityLock(ctx context.Context, id string, fn func(ctx context.Context) error) error {
+	locker := psm.pd.Config.Locker
+	if locker == nil || id == "" {
+		return fn(ctx)
+	}
+
+	key := lockKey(locker, id)
+	if isLockHeld(ctx, key) {
+		return fn(ctx)
+	}
+
+	lockCtx := ctx
+	if psm.pd.Config.LockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, psm.pd.Config.LockTimeout)
+		defer cancel()
+	}
+
+	unlock, err := locker.Lock(lockCtx, id)
+	if err != nil {
+		return plinkoerror.CreatePlinkoLockError(id, err, fmt.Sprintf("Lock for '%s' not acquired: %v", id, err))
+	}
+	defer unlock()
+
+	held, _ := ctx.Value(heldLockKey{}).(*heldLock)
+
+	return fn(context.WithValue(ctx, heldLockKey{}, &heldLock{key: key, parent: held}))
+}
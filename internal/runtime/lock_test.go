@@ -0,0 +1,162 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/locker"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFireSerializesEntity(t *testing.T) {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.Locker = locker.NewStriped(8)
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			time.Sleep(5 * time.Millisecond)
+			p.(*identifiedPayload).state = t.GetDestination()
+			return p, nil
+		})
+
+	psm := p.Compile().StateMachine
+
+	payload := &identifiedPayload{testPayload: testPayload{state: Created}, id: "order-1"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := psm.Fire(context.TODO(), payload, Open)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var failed []error
+	for err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	assert.Equal(t, 1, len(failed))
+	var pte *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(failed[0], &pte))
+}
+
+func TestFireNestedDoesNotDeadlock(t *testing.T) {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.Locker = locker.NewStriped(1)
+
+	var psm plinko.StateMachine
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			p.(*identifiedPayload).state = t.GetDestination()
+			return psm.Fire(ctx, p, Claim)
+		}).
+		Permit(Claim, Claimed)
+
+	p.Configure(Claimed).
+		OnEntry(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			p.(*identifiedPayload).state = t.GetDestination()
+			return p, nil
+		})
+
+	psm = p.Compile().StateMachine
+
+	payload, err := psm.Fire(context.TODO(), &identifiedPayload{testPayload: testPayload{state: Created}, id: "order-1"}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, Claimed, payload.GetState())
+}
+
+func TestFireNestedOnSharedStripeDoesNotDeadlock(t *testing.T) {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.Locker = locker.NewStriped(1)
+
+	var psm plinko.StateMachine
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			p.(*identifiedPayload).state = t.GetDestination()
+			if p.(*identifiedPayload).id == "order-1" {
+				related := &identifiedPayload{testPayload: testPayload{state: Created}, id: "order-2"}
+				if _, err := psm.Fire(ctx, related, Open); err != nil {
+					return p, err
+				}
+			}
+			return p, nil
+		})
+
+	psm = p.Compile().StateMachine
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := psm.Fire(context.TODO(), &identifiedPayload{testPayload: testPayload{state: Created}, id: "order-1"}, Open)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "nested fire on a shared stripe deadlocked")
+	}
+}
+
+func TestFireLockTimeout(t *testing.T) {
+	l := locker.NewStriped(1)
+
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.Locker = l
+	p.(*PlinkoDefinition).Config.LockTimeout = 5 * time.Millisecond
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened)
+
+	psm := p.Compile().StateMachine
+
+	unlock, err := l.Lock(context.TODO(), "order-1")
+	assert.Nil(t, err)
+	defer unlock()
+
+	payload := &identifiedPayload{testPayload: testPayload{state: Created}, id: "order-1"}
+
+	_, err = psm.Fire(context.TODO(), payload, Open)
+
+	var ple *plinkoerror.PlinkoLockError
+	assert.True(t, errors.As(err, &ple))
+	assert.Equal(t, "order-1", ple.ID)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, Created, payload.GetState())
+
+	// payloads without an identifier are not locked
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,100 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/internal/composition"
+)
+
+// compileOperations orders the operations of every state, and of the definition, by their
+// ordering constraints and priorities.
+func compileOperations(pd PlinkoDefinition, psm *plinkoStateMachine) []plinko.CompilerMessage {
+	var compilerMessages []plinko.CompilerMessage
+
+	psm.callbacks = map[plinko.State]*composition.CallbackDefinitions{}
+
+	for _, sd := range pd.Abs.StateDefinitions {
+		resolved, issues := sd.Callbacks.Resolve()
+		psm.callbacks[sd.State] = resolved
+
+		compilerMessages = append(compilerMessages, orderingMessages(fmt.Sprintf("State '%s'", sd.State), issues)...)
+	}
+
+	resolved, issues := pd.hooks().Resolve()
+	psm.hooks = resolved
+
+	compilerMessages = append(compilerMessages, orderingMessages("The definition", issues)...)
+
+	return compilerMessages
+}
+
+func orderingMessages(owner string, issues []composition.OrderingIssue) []plinko.CompilerMessage {
+	var compilerMessages []plinko.CompilerMessage
+
+	for _, issue := range issues {
+		if len(issue.Cycle) > 0 {
+			compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+				CompileMessage: plinko.CompileError,
+				Message:        fmt.Sprintf("%s orders its %s operations in a cycle: %s.", owner, issue.Chain, strings.Join(issue.Cycle, ", ")),
+			})
+			continue
+		}
+
+		compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+			CompileMessage: plinko.CompileWarning,
+			Message:        fmt.Sprintf("%s orders %s operation '%s' relative to '%s', which is not one of its %s operations.", owner, issue.Chain, issue.Operation, issue.Reference, issue.Chain),
+		})
+	}
+
+	return compilerMessages
+}
+
+// callbacksOf returns the operations of the state in the order resolved at compile time.  A
+// state configured, or given operations, after Compile has no resolved chain to match, so its
+// operations run in the order they were declared until the definition is compiled again.
+func (psm plinkoStateMachine) callbacksOf(sd *InternalStateDefinition) *composition.CallbackDefinitions {
+	cd, ok := psm.callbacks[sd.State]
+	if !ok || stale(cd, sd.Callbacks) {
+		return sd.Callbacks
+	}
+
+	return cd
+}
+
+// hooksOf returns the operations of the definition run on every transition, in the order
+// resolved at compile time.  Like those of a state, operations added after Compile run in the
+// order they were declared until the definition is compiled again.
+func (psm plinkoStateMachine) hooksOf() *composition.CallbackDefinitions {
+	if psm.pd.Hooks != nil && stale(psm.hooks, psm.pd.Hooks) {
+		return psm.pd.Hooks
+	}
+
+	return psm.hooks
+}
+
+// stale reports whether operations were added to the state after its chains were resolved.
+func stale(resolved, declared *composition.CallbackDefinitions) bool {
+	return len(resolved.OnEntryFn) != len(declared.OnEntryFn) ||
+		len(resolved.OnExitFn) != len(declared.OnExitFn) ||
+		len(resolved.OnErrorFn) != len(declared.OnErrorFn)
+}
+
+// Operations returns the operations of the state in the order they run, and false when the
+// state is not defined.  Operations added after Compile run, and are listed, in declaration
+// order until the definition is compiled again.
+func (psm plinkoStateMachine) Operations(state plinko.State) (plinko.OperationChains, bool) {
+	sd, ok := (*psm.pd.States)[state]
+	if !ok {
+		return plinko.OperationChains{}, false
+	}
+
+	return describeOperations(psm.callbacksOf(sd)), true
+}
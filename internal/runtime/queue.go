@@ -0,0 +1,66 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shipt/plinko"
+)
+
+// triggerQueue holds the follow-up triggers raised by operations during a single Fire call.
+type triggerQueue struct {
+	mu       sync.Mutex
+	triggers []plinko.Trigger
+}
+
+func (q *triggerQueue) Enqueue(trigger plinko.Trigger) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.triggers = append(q.triggers, trigger)
+}
+
+func (q *triggerQueue) dequeue() (plinko.Trigger, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.triggers) == 0 {
+		return "", false
+	}
+
+	trigger := q.triggers[0]
+	q.triggers = q.triggers[1:]
+
+	return trigger, true
+}
+
+type transitionResultsKey struct{}
+
+// transitionResults collects the transitions executed on behalf of FireWithResults.
+type transitionResults struct {
+	list []plinko.TransitionResult
+}
+
+func withTransitionResults(ctx context.Context, results *transitionResults) context.Context {
+	return context.WithValue(ctx, transitionResultsKey{}, results)
+}
+
+func recordResult(ctx context.Context, transitionInfo plinko.TransitionInfo, err error) {
+	results, ok := ctx.Value(transitionResultsKey{}).(*transitionResults)
+	if !ok {
+		return
+	}
+
+	results.list = append(results.list, plinko.TransitionResult{
+		Source:      transitionInfo.GetSource(),
+		Destination: transitionInfo.GetDestination(),
+		Trigger:     transitionInfo.GetTrigger(),
+		Err:         err,
+	})
+}
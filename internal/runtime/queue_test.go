@@ -0,0 +1,146 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func enqueueFn(trigger plinko.Trigger) plinko.Operation {
+	return func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+		if !plinko.EnqueueTrigger(ctx, trigger) {
+			return p, errors.New("no trigger queue")
+		}
+
+		return p, nil
+	}
+}
+
+func TestFireDrainsTriggerQueue(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(TransitionFn(false)).
+		OnEntry(enqueueFn(Claim)).
+		Permit(Claim, Claimed)
+
+	p.Configure(Claimed).
+		OnEntry(TransitionFn(false))
+
+	var actions []string
+	p.SideEffect(func(_ context.Context, sa plinko.StateAction, payload plinko.Payload, ti plinko.TransitionInfo, elapsed int64) {
+		actions = append(actions, fmt.Sprintf("%s:%s", ti.GetTrigger(), sa))
+	})
+
+	psm := p.Compile().StateMachine
+
+	payload := &testPayload{state: Created}
+	pr, results, err := psm.FireWithResults(context.TODO(), payload, Open)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Claimed, pr.GetState())
+	assert.Equal(t, []plinko.TransitionResult{
+		{Source: Created, Destination: Opened, Trigger: Open},
+		{Source: Opened, Destination: Claimed, Trigger: Claim},
+	}, results)
+
+	// the follow-up transition starts only after the side effects of the first completed
+	assert.Equal(t, []string{
+		"Open:BeforeTransition", "Open:MiddleTransition", "Open:AfterTransition",
+		"Claim:BeforeTransition", "Claim:MiddleTransition", "Claim:AfterTransition",
+	}, actions)
+}
+
+func TestFireTriggerQueueDepthLimit(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		OnEntry(enqueueFn(Open)).
+		PermitReentry(Open)
+
+	psm := p.Compile().StateMachine
+
+	payload := &testPayload{state: Created}
+	_, results, err := psm.FireWithResults(context.TODO(), payload, Open)
+
+	var qe *plinkoerror.PlinkoQueueDepthError
+	assert.True(t, errors.As(err, &qe))
+	assert.Equal(t, plinko.DefaultMaxTriggerQueueDepth, qe.Depth)
+	assert.Equal(t, plinko.DefaultMaxTriggerQueueDepth+1, len(results))
+}
+
+func TestFireTriggerQueueStopsOnError(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(enqueueFn(Claim)).
+		OnEntry(enqueueFn(Cancel)).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled)
+
+	psm := p.Compile().StateMachine
+
+	payload := &testPayload{state: Created}
+	_, results, err := psm.FireWithResults(context.TODO(), payload, Open)
+
+	var te *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, Claim, te.Trigger)
+	assert.Equal(t, 1, len(results))
+}
+
+func TestEnqueueTriggerOutsideTransition(t *testing.T) {
+	assert.False(t, plinko.EnqueueTrigger(context.TODO(), Open))
+}
+
+func TestQueuedTriggersRunThroughMiddleware(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	var seen []plinko.Trigger
+	p.Use(func(next plinko.FireFunc) plinko.FireFunc {
+		return func(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, error) {
+			seen = append(seen, trigger)
+			if trigger == Claim {
+				return payload, errors.New("not authorized")
+			}
+
+			return next(ctx, payload, trigger)
+		}
+	})
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(TransitionFn(false)).
+		OnEntry(enqueueFn(Claim)).
+		Permit(Claim, Claimed)
+
+	p.Configure(Claimed).
+		OnEntry(TransitionFn(false))
+
+	psm := p.Compile().StateMachine
+
+	pr, err := psm.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.EqualError(t, err, "not authorized")
+	assert.Equal(t, Opened, pr.GetState())
+	assert.Equal(t, []plinko.Trigger{Open, Claim}, seen)
+}
@@ -0,0 +1,466 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/internal/sideeffects"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+// activeState returns the state currently active within the region.  A region the payload has
+// no record of is treated as being in its initial state.
+func (psm plinkoStateMachine) activeState(active map[plinko.State]plinko.State, region *InternalStateDefinition) *InternalStateDefinition {
+	state := active[region.State]
+	if sd := (*psm.pd.States)[state]; sd != nil && (sd == region || psm.pd.isDescendant(state, region.State)) {
+		return sd
+	}
+
+	if initial := psm.initialStates[region.State]; initial != nil {
+		return initial
+	}
+
+	return region
+}
+
+// resolveRegionTrigger finds the trigger on the active state of a region or its ancestors within
+// the region.  Triggers of the parallel state and above are resolved as regular transitions.
+func (psm plinkoStateMachine) resolveRegionTrigger(active, region *InternalStateDefinition, trigger plinko.Trigger) *TriggerDefinition {
+	for _, sd := range psm.pd.lineage(active) {
+		if td, ok := sd.Triggers[trigger]; ok {
+			return td
+		}
+
+		if sd == region {
+			break
+		}
+	}
+
+	return nil
+}
+
+// regionTransition dispatches the trigger to every region of the parallel state that accepts it.
+// It reports the trigger as unhandled when no region accepts it, leaving it to the parallel
+// state and its ancestors.  The transitions of every region are resolved, and their guards
+// evaluated, against the region states before any of them run; when one fails, the regions
+// that had already moved are put back in the states they were in.  The after transition side
+// effects, journal entries and results of the regions are held until every region has moved,
+// so a failed dispatch reports none of the rewound transitions.
+func (psm plinkoStateMachine) regionTransition(ctx context.Context, payload plinko.Payload, parallel *InternalStateDefinition, trigger plinko.Trigger) (plinko.Payload, bool, error) {
+	regional, ok := payload.(plinko.RegionalPayload)
+	if !ok {
+		return payload, false, nil
+	}
+
+	type regionMove struct {
+		td                          *sideeffects.TransitionDef
+		source, destination, region *InternalStateDefinition
+	}
+
+	snapshot := map[plinko.State]plinko.State{}
+	for region, state := range regional.GetRegionStates() {
+		snapshot[region] = state
+	}
+
+	var moves []regionMove
+	var guardErr error
+	for _, region := range psm.regions[parallel.State] {
+		source := psm.activeState(snapshot, region)
+
+		triggerData := psm.resolveRegionTrigger(source, region, trigger)
+		if triggerData == nil {
+			continue
+		}
+
+		destination := (*psm.pd.States)[triggerData.DestinationState]
+		if destination == nil {
+			return payload, true, plinkoerror.CreatePlinkoStateError(triggerData.DestinationState, fmt.Sprintf("State not found in definition of states: %s", triggerData.DestinationState))
+		}
+
+		td := &sideeffects.TransitionDef{
+			Source:      source.State,
+			Destination: destination.State,
+			Trigger:     trigger,
+		}
+
+		if triggerData.Predicate != nil {
+			if err := triggerData.Predicate(ctx, payload, td); err != nil {
+				guardErr = err
+				continue
+			}
+		}
+
+		moves = append(moves, regionMove{td: td, source: source, destination: destination, region: region})
+	}
+
+	if len(moves) == 0 {
+		if guardErr != nil {
+			return payload, true, plinkoerror.CreatePlinkoGuardError(trigger, guardErr, fmt.Sprintf("Conditional Trigger '%s' conditions not met for any region of state: %s", trigger, parallel.State))
+		}
+		return payload, false, nil
+	}
+
+	held := &heldCompletions{}
+	heldCtx := context.WithValue(ctx, heldCompletionsKey{}, held)
+	for _, move := range moves {
+		var err error
+		if payload, err = psm.runTransition(heldCtx, payload, move.td, move.source, move.destination, move.region, time.Now()); err != nil {
+			return restoreRegions(payload, snapshot), true, err
+		}
+	}
+
+	if err := held.complete(); err != nil {
+		return payload, true, err
+	}
+
+	if psm.regionsFinal(payload, parallel) {
+		if join, ok := psm.joins[parallel.State]; ok {
+			plinko.EnqueueTrigger(ctx, join)
+		}
+	}
+
+	return payload, true, nil
+}
+
+type heldCompletionsKey struct{}
+
+// heldCompletions collects the completion of transitions that must not be reported until the
+// transitions of every other region have succeeded.
+type heldCompletions struct {
+	list []func() error
+}
+
+// hold defers the completion of a transition when the context collects them, reporting if it did.
+func hold(ctx context.Context, complete func() error) bool {
+	held, ok := ctx.Value(heldCompletionsKey{}).(*heldCompletions)
+	if ok {
+		held.list = append(held.list, complete)
+	}
+
+	return ok
+}
+
+// complete runs the held completions in the order the transitions took place, returning the
+// first error.
+func (h *heldCompletions) complete() error {
+	var first error
+	for _, complete := range h.list {
+		if err := complete(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// regionStates copies the active region states of the payload before a transition that is not
+// itself within a region, as leaving or entering a parallel state changes them.
+func regionStates(payload plinko.Payload, region *InternalStateDefinition) map[plinko.State]plinko.State {
+	regional, ok := payload.(plinko.RegionalPayload)
+	if !ok || region != nil {
+		return nil
+	}
+
+	states := map[plinko.State]plinko.State{}
+	for r, state := range regional.GetRegionStates() {
+		states[r] = state
+	}
+
+	return states
+}
+
+// restoreRegions puts every region of the payload back in the state recorded before a failed
+// transition.  Regions the transition started are cleared.
+func restoreRegions(payload plinko.Payload, snapshot map[plinko.State]plinko.State) plinko.Payload {
+	regional, ok := payload.(plinko.RegionalPayload)
+	if !ok || snapshot == nil {
+		return payload
+	}
+
+	changed := map[plinko.State]plinko.State{}
+	current := regional.GetRegionStates()
+	for region, state := range current {
+		if snapshot[region] != state {
+			changed[region] = snapshot[region]
+		}
+	}
+	for region, state := range snapshot {
+		if current[region] != state {
+			changed[region] = state
+		}
+	}
+
+	for region, state := range changed {
+		regional.SetRegionState(region, state)
+	}
+
+	return payload
+}
+
+// canFireRegion reports if any region of the parallel state accepts the trigger.
+func (psm plinkoStateMachine) canFireRegion(ctx context.Context, payload plinko.Payload, parallel *InternalStateDefinition, trigger plinko.Trigger) bool {
+	regional, ok := payload.(plinko.RegionalPayload)
+	if !ok {
+		return false
+	}
+
+	active := regional.GetRegionStates()
+	for _, region := range psm.regions[parallel.State] {
+		source := psm.activeState(active, region)
+
+		triggerData := psm.resolveRegionTrigger(source, region, trigger)
+		if triggerData == nil {
+			continue
+		}
+
+		if triggerData.Predicate == nil || triggerData.Predicate(ctx, payload, sideeffects.TransitionDef{
+			Source:      source.State,
+			Destination: triggerData.DestinationState,
+			Trigger:     trigger,
+		}) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// regionTriggers lists the triggers accepted by the active states of the regions.
+func (psm plinkoStateMachine) regionTriggers(payload plinko.Payload, parallel *InternalStateDefinition) []plinko.Trigger {
+	regional, ok := payload.(plinko.RegionalPayload)
+	if !ok {
+		return nil
+	}
+
+	var triggers []plinko.Trigger
+	active := regional.GetRegionStates()
+	for _, region := range psm.regions[parallel.State] {
+		for _, sd := range psm.pd.lineage(psm.activeState(active, region)) {
+			for trigger := range sd.Triggers {
+				triggers = append(triggers, trigger)
+			}
+
+			if sd == region {
+				break
+			}
+		}
+	}
+
+	return triggers
+}
+
+// regionsFinal reports if every region of the parallel state is in a final state.
+func (psm plinkoStateMachine) regionsFinal(payload plinko.Payload, parallel *InternalStateDefinition) bool {
+	regional, ok := payload.(plinko.RegionalPayload)
+	regions := psm.regions[parallel.State]
+	if !ok || len(regions) == 0 {
+		return false
+	}
+
+	active := regional.GetRegionStates()
+	for _, region := range regions {
+		if !psm.activeState(active, region).info.Final {
+			return false
+		}
+	}
+
+	return true
+}
+
+// withRegionExits prepends the active states of each region, innermost first, when the
+// transition leaves a parallel state, so they are exited before the parallel state itself.
+func (psm plinkoStateMachine) withRegionExits(payload plinko.Payload, exiting []*InternalStateDefinition) []*InternalStateDefinition {
+	regional, ok := payload.(plinko.RegionalPayload)
+	if !ok || !exiting[0].info.Parallel {
+		return exiting
+	}
+
+	var states []*InternalStateDefinition
+	active := regional.GetRegionStates()
+	for _, region := range psm.regions[exiting[0].State] {
+		for _, sd := range psm.pd.lineage(psm.activeState(active, region)) {
+			states = append(states, sd)
+
+			if sd == region {
+				break
+			}
+		}
+	}
+
+	return append(states, exiting...)
+}
+
+// clearRegions forgets the active region states once the payload has left a parallel state.
+func (psm plinkoStateMachine) clearRegions(payload plinko.Payload, source *InternalStateDefinition) {
+	regional, ok := payload.(plinko.RegionalPayload)
+	if !ok || !source.info.Parallel {
+		return
+	}
+
+	for _, region := range psm.regions[source.State] {
+		regional.SetRegionState(region.State, "")
+	}
+}
+
+// enterRegions starts every region of a parallel state in its initial state, running the entry
+// operations of the region and the initial state.
+func (psm plinkoStateMachine) enterRegions(ctx context.Context, payload plinko.Payload, destination *InternalStateDefinition, td *sideeffects.TransitionDef) (plinko.Payload, error) {
+	if !destination.info.Parallel {
+		return payload, nil
+	}
+
+	if _, ok := payload.(plinko.RegionalPayload); !ok {
+		return payload, plinkoerror.CreatePlinkoStateError(destination.State, fmt.Sprintf("State %s has orthogonal regions, but the payload does not implement RegionalPayload", destination.State))
+	}
+
+	for _, region := range psm.regions[destination.State] {
+		entering := []*InternalStateDefinition{region}
+		if initial := psm.initialStates[region.State]; initial != nil {
+			entering = append(entering, initial)
+		}
+
+		var err error
+		if payload, _, err = psm.executeEntryChains(ctx, entering, payload, td); err != nil {
+			return payload, err
+		}
+
+		payload.(plinko.RegionalPayload).SetRegionState(region.State, entering[len(entering)-1].State)
+	}
+
+	return payload, nil
+}
+
+// compileRegions resolves the regions, initial states and joins of the parallel states and
+// validates that transitions stay within the region they start from.
+func compileRegions(pd PlinkoDefinition, psm *plinkoStateMachine) []plinko.CompilerMessage {
+	var compilerMessages []plinko.CompilerMessage
+
+	psm.regions = map[plinko.State][]*InternalStateDefinition{}
+	psm.initialStates = map[plinko.State]*InternalStateDefinition{}
+	psm.joins = map[plinko.State]plinko.Trigger{}
+
+	for _, sd := range pd.Abs.StateDefinitions {
+		if sd.info.Initial && sd.info.Parent != "" {
+			if _, ok := psm.initialStates[sd.info.Parent]; ok {
+				compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+					CompileMessage: plinko.CompileError,
+					Message:        fmt.Sprintf("State '%s' declares more than one initial substate.", sd.info.Parent),
+				})
+			}
+			psm.initialStates[sd.info.Parent] = sd
+		}
+
+		if parent := (*pd.States)[sd.info.Parent]; parent != nil && parent.info.Parallel {
+			psm.regions[parent.State] = append(psm.regions[parent.State], sd)
+		}
+	}
+
+	for _, sd := range pd.Abs.StateDefinitions {
+		if sd.info.Parallel {
+			compilerMessages = append(compilerMessages, compileParallelState(pd, psm, sd)...)
+		}
+
+		region := regionOf(pd, sd)
+		for _, td := range sd.Triggers {
+			if td.Config.Join && !sd.info.Parallel {
+				compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+					CompileMessage: plinko.CompileError,
+					Message:        fmt.Sprintf("State '%s' has no orthogonal regions: Trigger '%s' declares a join from this state.", sd.State, td.Name),
+				})
+			}
+
+			destination := (*pd.States)[td.DestinationState]
+			if destination == nil || regionOf(pd, destination) == region {
+				continue
+			}
+
+			if region != nil && destination != region && !pd.isDescendant(destination.State, region.State) {
+				compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+					CompileMessage: plinko.CompileError,
+					Message:        fmt.Sprintf("State '%s' is outside region '%s': Trigger '%s' declares a transition leaving the region.", td.DestinationState, region.State, td.Name),
+				})
+			} else if region == nil {
+				compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+					CompileMessage: plinko.CompileError,
+					Message:        fmt.Sprintf("State '%s' is within a region: Trigger '%s' declares a transition into the region, enter its parallel state instead.", td.DestinationState, td.Name),
+				})
+			}
+		}
+	}
+
+	return compilerMessages
+}
+
+func compileParallelState(pd PlinkoDefinition, psm *plinkoStateMachine, sd *InternalStateDefinition) []plinko.CompilerMessage {
+	var compilerMessages []plinko.CompilerMessage
+
+	if regionOf(pd, sd) != nil {
+		compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+			CompileMessage: plinko.CompileError,
+			Message:        fmt.Sprintf("State '%s' has orthogonal regions and cannot be nested within the region of another parallel state.", sd.State),
+		})
+	}
+
+	regions := psm.regions[sd.State]
+	if len(regions) < 2 {
+		compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+			CompileMessage: plinko.CompileWarning,
+			Message:        fmt.Sprintf("State '%s' is parallel but declares fewer than two regions.", sd.State),
+		})
+	}
+
+	for _, td := range sd.Triggers {
+		if td.Config.Join {
+			psm.joins[sd.State] = td.Name
+		}
+	}
+
+	for _, region := range regions {
+		if pd.hasSubstates(region.State) && psm.initialStates[region.State] == nil {
+			compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+				CompileMessage: plinko.CompileError,
+				Message:        fmt.Sprintf("Region '%s' of state '%s' declares no initial substate.", region.State, sd.State),
+			})
+		}
+
+		if _, ok := psm.joins[sd.State]; ok && !hasFinalState(pd, region) {
+			compilerMessages = append(compilerMessages, plinko.CompilerMessage{
+				CompileMessage: plinko.CompileError,
+				Message:        fmt.Sprintf("Region '%s' has no final state: the join of state '%s' can never fire.", region.State, sd.State),
+			})
+		}
+	}
+
+	return compilerMessages
+}
+
+// regionOf returns the region the state belongs to, or nil when it is not nested within a
+// parallel state.  A region is a direct substate of a parallel state.
+func regionOf(pd PlinkoDefinition, sd *InternalStateDefinition) *InternalStateDefinition {
+	lineage := pd.lineage(sd)
+	for i, s := range lineage {
+		if i+1 < len(lineage) && lineage[i+1].info.Parallel {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func hasFinalState(pd PlinkoDefinition, region *InternalStateDefinition) bool {
+	for _, sd := range pd.Abs.StateDefinitions {
+		if sd.info.Final && (sd == region || pd.isDescendant(sd.State, region.State)) {
+			return true
+		}
+	}
+
+	return false
+}
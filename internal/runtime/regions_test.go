@@ -0,0 +1,305 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+const Processing plinko.State = "Processing"
+const Fulfillment plinko.State = "Fulfillment"
+const Payment plinko.State = "Payment"
+const Packed plinko.State = "Packed"
+const Authorizing plinko.State = "Authorizing"
+const Authorized plinko.State = "Authorized"
+const Captured plinko.State = "Captured"
+const Completed plinko.State = "Completed"
+
+const Process plinko.Trigger = "Process"
+const Advance plinko.Trigger = "Advance"
+const Capture plinko.Trigger = "Capture"
+const Complete plinko.Trigger = "Complete"
+
+type regionalPayload struct {
+	testPayload
+	regions map[plinko.State]plinko.State
+}
+
+func (p *regionalPayload) GetRegionStates() map[plinko.State]plinko.State {
+	return p.regions
+}
+
+func (p *regionalPayload) SetRegionState(region plinko.State, state plinko.State) {
+	p.regions[region] = state
+}
+
+func stateOptions(opts ...plinko.StateOption) []plinko.StateOption {
+	return opts
+}
+
+func parallel(c *plinko.StateConfig) { c.Parallel = true }
+func initial(c *plinko.StateConfig)  { c.Initial = true }
+func final(c *plinko.StateConfig)    { c.Final = true }
+
+func createParallelDefinition(calls *[]string) plinko.PlinkoDefinition {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Process, Processing)
+
+	p.Configure(Processing, parallel).
+		OnEntry(recordingFn(calls, "enter Processing")).
+		OnExit(recordingFn(calls, "exit Processing")).
+		Permit(Cancel, Canceled).
+		PermitJoin(Complete, Completed)
+
+	p.Configure(Fulfillment, substateOf(Processing)).
+		OnEntry(recordingFn(calls, "enter Fulfillment"))
+
+	p.Configure(Picking, stateOptions(substateOf(Fulfillment), initial)...).
+		OnEntry(recordingFn(calls, "enter Picking")).
+		OnExit(recordingFn(calls, "exit Picking")).
+		Permit(Advance, Packed)
+
+	p.Configure(Packed, stateOptions(substateOf(Fulfillment), final)...).
+		OnEntry(recordingFn(calls, "enter Packed"))
+
+	p.Configure(Payment, substateOf(Processing)).
+		OnEntry(recordingFn(calls, "enter Payment"))
+
+	p.Configure(Authorizing, stateOptions(substateOf(Payment), initial)...).
+		OnEntry(recordingFn(calls, "enter Authorizing")).
+		OnExit(recordingFn(calls, "exit Authorizing")).
+		Permit(Advance, Authorized)
+
+	p.Configure(Authorized, substateOf(Payment)).
+		OnExit(recordingFn(calls, "exit Authorized")).
+		Permit(Capture, Captured)
+
+	p.Configure(Captured, stateOptions(substateOf(Payment), final)...)
+
+	p.Configure(Completed).
+		OnEntry(recordingFn(calls, "enter Completed"))
+
+	p.Configure(Canceled)
+
+	return p
+}
+
+func TestParallelRegions(t *testing.T) {
+	var calls []string
+	co := createParallelDefinition(&calls).Compile()
+	for _, m := range co.Messages {
+		assert.NotEqual(t, plinko.CompileError, m.CompileMessage, m.Message)
+	}
+
+	psm := co.StateMachine
+	payload := &regionalPayload{testPayload: testPayload{state: Created}, regions: map[plinko.State]plinko.State{}}
+
+	_, err := psm.Fire(context.TODO(), payload, Process)
+	assert.Nil(t, err)
+	payload.state = Processing
+	assert.Equal(t, []string{"enter Processing", "enter Fulfillment", "enter Picking", "enter Payment", "enter Authorizing"}, calls)
+	assert.Equal(t, map[plinko.State]plinko.State{Fulfillment: Picking, Payment: Authorizing}, payload.regions)
+
+	triggers, err := psm.EnumerateActiveTriggers(payload)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []plinko.Trigger{Advance, Cancel, Complete}, triggers)
+	assert.NotNil(t, psm.CanFire(context.TODO(), payload, Complete))
+	assert.NotNil(t, psm.CanFire(context.TODO(), payload, Capture))
+
+	// the trigger is dispatched to both regions
+	calls = nil
+	_, results, err := psm.FireWithResults(context.TODO(), payload, Advance)
+	assert.Nil(t, err)
+	assert.Equal(t, map[plinko.State]plinko.State{Fulfillment: Packed, Payment: Authorized}, payload.regions)
+	assert.Equal(t, []string{"exit Picking", "enter Packed", "exit Authorizing"}, calls)
+	assert.Equal(t, []plinko.TransitionResult{
+		{Source: Picking, Destination: Packed, Trigger: Advance},
+		{Source: Authorizing, Destination: Authorized, Trigger: Advance},
+	}, results)
+
+	// reaching the final state in every region fires the join
+	calls = nil
+	assert.Nil(t, psm.CanFire(context.TODO(), payload, Capture))
+	_, results, err = psm.FireWithResults(context.TODO(), payload, Capture)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, plinko.TransitionResult{Source: Processing, Destination: Completed, Trigger: Complete}, results[1])
+	assert.Equal(t, []string{"exit Authorized", "exit Processing", "enter Completed"}, calls)
+	assert.Equal(t, map[plinko.State]plinko.State{Fulfillment: "", Payment: ""}, payload.regions)
+}
+
+func createGuardedParallelDefinition(guard plinko.Predicate, authorize plinko.Operation) plinko.PlinkoDefinition {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Process, Processing)
+
+	p.Configure(Processing, parallel)
+
+	p.Configure(Fulfillment, substateOf(Processing))
+
+	p.Configure(Picking, stateOptions(substateOf(Fulfillment), initial)...).
+		PermitIf(guard, Advance, Packed)
+
+	p.Configure(Packed, stateOptions(substateOf(Fulfillment), final)...)
+
+	p.Configure(Payment, substateOf(Processing))
+
+	p.Configure(Authorizing, stateOptions(substateOf(Payment), initial)...).
+		PermitIf(guard, Advance, Authorized)
+
+	p.Configure(Authorized, stateOptions(substateOf(Payment), final)...).
+		OnEntry(authorize)
+
+	return p
+}
+
+func TestParallelRegionFailureRestoresRegions(t *testing.T) {
+	p := createGuardedParallelDefinition(
+		func(context.Context, plinko.Payload, plinko.TransitionInfo) error { return nil },
+		func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, errors.New("authorization declined")
+		})
+
+	journal := &recordingJournal{}
+	p.(*PlinkoDefinition).Config.Journal = journal
+
+	var completed []plinko.State
+	p.FilteredSideEffect(plinko.AllowAfterTransition, func(_ context.Context, _ plinko.StateAction, _ plinko.Payload, ti plinko.TransitionInfo, _ int64) {
+		completed = append(completed, ti.GetDestination())
+	})
+
+	psm := p.Compile().StateMachine
+
+	payload := &regionalPayload{testPayload: testPayload{state: Processing}, regions: map[plinko.State]plinko.State{Fulfillment: Picking, Payment: Authorizing}}
+
+	_, results, err := psm.FireWithResults(context.TODO(), payload, Advance)
+	assert.EqualError(t, err, "authorization declined")
+	assert.Equal(t, map[plinko.State]plinko.State{Fulfillment: Picking, Payment: Authorizing}, payload.regions)
+	assert.Empty(t, completed)
+	assert.Empty(t, journal.entries)
+	assert.Len(t, results, 1)
+	assert.Equal(t, Authorizing, results[0].Source)
+	assert.EqualError(t, results[0].Err, "authorization declined")
+
+	payload.regions = map[plinko.State]plinko.State{Fulfillment: Picking, Payment: Authorized}
+
+	_, results, err = psm.FireWithResults(context.TODO(), payload, Advance)
+	assert.NoError(t, err)
+	assert.Equal(t, []plinko.State{Packed}, completed)
+	assert.Len(t, journal.entries, 1)
+	assert.Len(t, results, 1)
+}
+
+func TestParallelRegionGuardsFail(t *testing.T) {
+	psm := createGuardedParallelDefinition(
+		func(context.Context, plinko.Payload, plinko.TransitionInfo) error { return errors.New("not ready") },
+		TransitionFn(false)).Compile().StateMachine
+
+	payload := &regionalPayload{testPayload: testPayload{state: Processing}, regions: map[plinko.State]plinko.State{Fulfillment: Picking, Payment: Authorizing}}
+
+	_, err := psm.Fire(context.TODO(), payload, Advance)
+
+	var pge *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &pge))
+	assert.EqualError(t, pge.Cause, "not ready")
+	assert.Equal(t, map[plinko.State]plinko.State{Fulfillment: Picking, Payment: Authorizing}, payload.regions)
+}
+
+func TestParallelExitFailureRestoresRegions(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Processing, parallel).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Fulfillment, substateOf(Processing))
+	p.Configure(Picking, stateOptions(substateOf(Fulfillment), initial)...).
+		Permit(Advance, Packed)
+	p.Configure(Packed, stateOptions(substateOf(Fulfillment), final)...)
+
+	p.Configure(Payment, substateOf(Processing))
+	p.Configure(Authorizing, stateOptions(substateOf(Payment), initial)...).
+		Permit(Advance, Authorized)
+	p.Configure(Authorized, stateOptions(substateOf(Payment), final)...)
+
+	p.Configure(Canceled).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, errors.New("boom")
+		})
+
+	psm := p.Compile().StateMachine
+
+	payload := &regionalPayload{testPayload: testPayload{state: Processing}, regions: map[plinko.State]plinko.State{Fulfillment: Packed, Payment: Authorized}}
+
+	pr, err := psm.Fire(context.TODO(), payload, Cancel)
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, Processing, pr.GetState())
+	assert.Equal(t, map[plinko.State]plinko.State{Fulfillment: Packed, Payment: Authorized}, payload.regions)
+}
+
+func TestParallelExit(t *testing.T) {
+	var calls []string
+	psm := createParallelDefinition(&calls).Compile().StateMachine
+
+	payload := &regionalPayload{testPayload: testPayload{state: Processing}, regions: map[plinko.State]plinko.State{Fulfillment: Packed}}
+
+	_, err := psm.Fire(context.TODO(), payload, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"exit Authorizing", "exit Processing"}, calls)
+}
+
+func TestParallelWithoutRegionalPayload(t *testing.T) {
+	var calls []string
+	psm := createParallelDefinition(&calls).Compile().StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: Created}, Process)
+
+	var se *plinkoerror.PlinkoStateError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, Processing, se.State)
+
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Processing}, Complete)
+	assert.NotNil(t, err)
+}
+
+func TestParallelCompileErrors(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Process, Processing).
+		Permit(Submit, Picking).
+		PermitJoin(Complete, Completed)
+
+	p.Configure(Processing, parallel).
+		PermitJoin(Complete, Completed)
+
+	p.Configure(Fulfillment, substateOf(Processing))
+	p.Configure(Picking, substateOf(Fulfillment)).
+		Permit(Advance, Completed)
+
+	p.Configure(Completed)
+
+	var messages []string
+	for _, m := range p.Compile().Messages {
+		messages = append(messages, m.Message)
+	}
+
+	assert.Contains(t, messages, "State 'Processing' is parallel but declares fewer than two regions.")
+	assert.Contains(t, messages, "Region 'Fulfillment' of state 'Processing' declares no initial substate.")
+	assert.Contains(t, messages, "Region 'Fulfillment' has no final state: the join of state 'Processing' can never fire.")
+	assert.Contains(t, messages, "State 'Created' has no orthogonal regions: Trigger 'Complete' declares a join from this state.")
+	assert.Contains(t, messages, "State 'Completed' is outside region 'Fulfillment': Trigger 'Advance' declares a transition leaving the region.")
+	assert.Contains(t, messages, "State 'Picking' is within a region: Trigger 'Submit' declares a transition into the region, enter its parallel state instead.")
+}
@@ -0,0 +1,60 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+
+	"github.com/shipt/plinko"
+)
+
+// payloadSnapshot is a copy of the payload taken before a transition, along with the payload
+// it was copied from.
+type payloadSnapshot struct {
+	original plinko.Payload
+	copy     plinko.Payload
+}
+
+// snapshot copies the payload before a transition, when rollback is configured and the
+// payload can be cloned.
+func (psm plinkoStateMachine) snapshot(payload plinko.Payload) *payloadSnapshot {
+	if !psm.pd.Config.RollbackOnError {
+		return nil
+	}
+
+	if cloneable, ok := payload.(plinko.Cloneable); ok {
+		return &payloadSnapshot{original: payload, copy: cloneable.Clone()}
+	}
+
+	return nil
+}
+
+// rollback restores the snapshot taken before a failed transition, handing the payload the
+// failed operations left behind to the error operations through the context.  Payloads that
+// implement plinko.Restorable are restored in place, so the caller's payload is rolled back
+// too; others are replaced by the copy.
+func rollback(ctx context.Context, payload plinko.Payload, snapshot *payloadSnapshot) (context.Context, plinko.Payload) {
+	if snapshot == nil {
+		return ctx, payload
+	}
+
+	restorable, ok := snapshot.original.(plinko.Restorable)
+	if !ok {
+		return plinko.WithPartialPayload(ctx, payload), snapshot.copy
+	}
+
+	// the failed operations may have changed the original in place, so the partial payload is
+	// copied before the original is restored
+	partial := payload
+	if cloneable, ok := payload.(plinko.Cloneable); ok {
+		partial = cloneable.Clone()
+	}
+
+	restorable.Restore(snapshot.copy)
+
+	return plinko.WithPartialPayload(ctx, partial), snapshot.original
+}
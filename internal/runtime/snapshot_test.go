@@ -0,0 +1,147 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+type cloneablePayload struct {
+	state plinko.State
+	items []string
+}
+
+func (p *cloneablePayload) GetState() plinko.State {
+	return p.state
+}
+
+func (p *cloneablePayload) Clone() plinko.Payload {
+	c := *p
+	c.items = append([]string(nil), p.items...)
+
+	return &c
+}
+
+func addItem(item string, err error) plinko.Operation {
+	return func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+		cp := p.(*cloneablePayload)
+		cp.items = append(cp.items, item)
+
+		return cp, err
+	}
+}
+
+func createRollbackDefinition(rollback bool, onError plinko.ErrorOperation) plinko.StateMachine {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.RollbackOnError = rollback
+
+	p.Configure(Created).
+		OnExit(addItem("exit", nil)).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntry(addItem("entry", nil)).
+		OnEntry(addItem("failed", errors.New("entry failed"))).
+		OnError(onError)
+
+	p.Configure(Canceled).
+		OnEntry(addItem("canceled", nil))
+
+	return p.Compile().StateMachine
+}
+
+func TestFireRollsBackFailedTransition(t *testing.T) {
+	var partial plinko.Payload
+	var errorPayload plinko.Payload
+
+	psm := createRollbackDefinition(true, func(ctx context.Context, p plinko.Payload, _ plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+		errorPayload = p
+		partial, _ = plinko.PartialPayload(ctx)
+		return p, nil
+	})
+
+	payload := &cloneablePayload{state: Created, items: []string{"start"}}
+
+	pr, err := psm.Fire(context.TODO(), payload, Open)
+	assert.EqualError(t, err, "entry failed")
+	assert.Equal(t, []string{"start"}, pr.(*cloneablePayload).items)
+	assert.Equal(t, pr, errorPayload)
+	assert.Equal(t, []string{"start", "exit", "entry", "failed"}, partial.(*cloneablePayload).items)
+
+	// successful transitions keep the changes their operations made
+	pr, err = psm.Fire(context.TODO(), pr, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"start", "exit", "canceled"}, pr.(*cloneablePayload).items)
+}
+
+func TestFireWithoutRollback(t *testing.T) {
+	var rolledBack bool
+
+	psm := createRollbackDefinition(false, func(ctx context.Context, p plinko.Payload, _ plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+		_, rolledBack = plinko.PartialPayload(ctx)
+		return p, nil
+	})
+
+	pr, err := psm.Fire(context.TODO(), &cloneablePayload{state: Created}, Open)
+	assert.NotNil(t, err)
+	assert.False(t, rolledBack)
+	assert.Equal(t, []string{"exit", "entry", "failed"}, pr.(*cloneablePayload).items)
+}
+
+type restorablePayload struct {
+	cloneablePayload
+}
+
+func (p *restorablePayload) Clone() plinko.Payload {
+	return &restorablePayload{cloneablePayload: *p.cloneablePayload.Clone().(*cloneablePayload)}
+}
+
+func (p *restorablePayload) Restore(from plinko.Payload) {
+	*p = *from.(*restorablePayload)
+}
+
+func TestFireRollsBackInPlace(t *testing.T) {
+	var partial plinko.Payload
+
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.RollbackOnError = true
+
+	appendItem := func(item string, err error) plinko.Operation {
+		return func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			rp := p.(*restorablePayload)
+			rp.items = append(rp.items, item)
+			return rp, err
+		}
+	}
+
+	p.Configure(Created).
+		OnExit(appendItem("exit", nil)).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(appendItem("failed", errors.New("entry failed"))).
+		OnError(func(ctx context.Context, p plinko.Payload, _ plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+			partial, _ = plinko.PartialPayload(ctx)
+			return p, nil
+		})
+
+	psm := p.Compile().StateMachine
+
+	payload := &restorablePayload{cloneablePayload{state: Created, items: []string{"start"}}}
+
+	pr, err := psm.Fire(context.TODO(), payload, Open)
+	assert.EqualError(t, err, "entry failed")
+	assert.True(t, pr == payload)
+	assert.Equal(t, []string{"start"}, payload.items)
+	assert.Equal(t, []string{"start", "exit", "failed"}, partial.(*restorablePayload).items)
+}
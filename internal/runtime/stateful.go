@@ -0,0 +1,48 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+// assignState sets the destination on payloads that implement plinko.StatefulPayload.  A
+// transition within a region of a parallel state leaves the payload in the parallel state.
+func assignState(payload plinko.Payload, destination, region *InternalStateDefinition) plinko.Payload {
+	if sp, ok := payload.(plinko.StatefulPayload); ok && region == nil {
+		return sp.SetState(destination.State)
+	}
+
+	return payload
+}
+
+// restoreState puts a payload that implements plinko.StatefulPayload back in the state it was in
+// before a transition whose entry failed, as assignState has already moved it to the destination.
+func restoreState(payload plinko.Payload, state plinko.State, region *InternalStateDefinition) plinko.Payload {
+	if sp, ok := payload.(plinko.StatefulPayload); ok && region == nil {
+		return sp.SetState(state)
+	}
+
+	return payload
+}
+
+// verifyState checks that a payload that implements plinko.StatefulPayload is in the
+// destination once the entry operations have run.
+func verifyState(payload plinko.Payload, destination, region *InternalStateDefinition) error {
+	if _, ok := payload.(plinko.StatefulPayload); !ok || region != nil {
+		return nil
+	}
+
+	if state := payload.GetState(); state != destination.State {
+		return plinkoerror.CreatePlinkoStateError(state, fmt.Sprintf("Payload is in state '%s' after its transition to state '%s'", state, destination.State))
+	}
+
+	return nil
+}
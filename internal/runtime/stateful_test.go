@@ -0,0 +1,110 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+// statefulPayload is a value payload, so SetState returns the updated copy.
+type statefulPayload struct {
+	state plinko.State
+	seen  []plinko.State
+}
+
+func (p statefulPayload) GetState() plinko.State {
+	return p.state
+}
+
+func (p statefulPayload) SetState(state plinko.State) plinko.Payload {
+	p.state = state
+	return p
+}
+
+func recordState(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+	sp := p.(statefulPayload)
+	sp.seen = append(sp.seen, sp.state)
+
+	return sp, nil
+}
+
+func TestFireAssignsState(t *testing.T) {
+	var errorState plinko.State
+
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		OnExit(recordState).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntry(recordState)
+
+	p.Configure(Canceled).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p.(statefulPayload).SetState(Returned), nil
+		}).
+		OnError(func(_ context.Context, p plinko.Payload, _ plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+			errorState = p.GetState()
+			return p, nil
+		})
+
+	psm := p.Compile().StateMachine
+
+	pr, err := psm.Fire(context.TODO(), statefulPayload{state: Created}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, pr.GetState())
+	assert.Equal(t, []plinko.State{Created, Opened}, pr.(statefulPayload).seen)
+
+	pr, err = psm.Fire(context.TODO(), statefulPayload{state: Created}, Cancel)
+	assert.Equal(t, "Payload is in state 'Returned' after its transition to state 'Canceled'", err.Error())
+	assert.Equal(t, Created, errorState)
+
+	var pse *plinkoerror.PlinkoStateError
+	assert.True(t, errors.As(err, &pse))
+	assert.Equal(t, Returned, pse.State)
+	assert.Equal(t, Created, pr.GetState())
+}
+
+func TestFireRestoresStateWhenEntryFails(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, errors.New("cannot open")
+		})
+
+	p.Configure(Canceled).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, errors.New("cannot cancel")
+		}).
+		OnError(func(_ context.Context, p plinko.Payload, m plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+			m.SetDestination(Returned)
+			return p.(statefulPayload).SetState(Returned), err
+		})
+
+	psm := p.Compile().StateMachine
+
+	pr, err := psm.Fire(context.TODO(), statefulPayload{state: Created}, Open)
+	assert.Equal(t, "cannot open", err.Error())
+	assert.Equal(t, Created, pr.GetState())
+
+	pr, err = psm.Fire(context.TODO(), statefulPayload{state: Created}, Cancel)
+	assert.Equal(t, "cannot cancel", err.Error())
+	assert.Equal(t, Returned, pr.GetState())
+}
@@ -0,0 +1,120 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+var errNoStore = errors.New("FireByID requires a Store to be configured on the definition")
+
+// FireByID loads the payload from the configured store, fires the trigger and saves the result
+// when the transition succeeds, or when a fatal error routed the payload to the failure state.
+// The save is all or nothing: when a queued follow-up trigger or a journal write fails, nothing
+// is saved, even though the side effects and journal entries of the transitions that completed
+// before it have already been raised.  The save is checked against the version that was
+// loaded, so a concurrent update of the same payload surfaces as a
+// plinkoerror.PlinkoConflictError.
+func (psm plinkoStateMachine) FireByID(ctx context.Context, id string, trigger plinko.Trigger) (plinko.Payload, error) {
+	store := psm.pd.Config.Store
+	if store == nil {
+		return nil, errNoStore
+	}
+
+	var run storedFire
+	var routed error
+	fireByID := func(ctx context.Context) error {
+		run = psm.loadFireSave(ctx, store, id, trigger)
+
+		// a payload routed to the failure state has been saved, so the transaction commits
+		var fse *plinkoerror.PlinkoFailureStateError
+		if errors.As(run.err, &fse) {
+			routed = run.err
+			return nil
+		}
+
+		return run.err
+	}
+
+	err := psm.withEntityLock(ctx, id, func(ctx context.Context) error {
+		var err error
+		if ts, ok := store.(plinko.TransactionalStore); ok {
+			err = ts.WithinTransaction(ctx, fireByID)
+		} else {
+			err = fireByID(ctx)
+		}
+
+		// the transitions of a failed fire were rolled back with it, so they are recorded
+		// on their own against the version the payload remains at
+		if run.failed {
+			err = recordFailedTransitions(ctx, store, id, run, err)
+		}
+
+		return err
+	})
+	if err == nil {
+		err = routed
+	}
+
+	return run.payload, err
+}
+
+// storedFire is the outcome of a FireByID.  failed is set when the fire itself failed, as
+// opposed to the load or save around it, in which case version is the version loaded.
+type storedFire struct {
+	payload plinko.Payload
+	version int64
+	results []plinko.TransitionResult
+	failed  bool
+	err     error
+}
+
+func (psm plinkoStateMachine) loadFireSave(ctx context.Context, store plinko.Store, id string, trigger plinko.Trigger) storedFire {
+	payload, version, err := store.Load(ctx, id)
+	if err != nil {
+		return storedFire{err: err}
+	}
+
+	results := &transitionResults{}
+	payload, fireErr := psm.fire(withTransitionResults(ctx, results), payload, trigger)
+
+	// a payload routed to the failure state is saved along with the error that routed it
+	var fse *plinkoerror.PlinkoFailureStateError
+	if fireErr != nil && !errors.As(fireErr, &fse) {
+		return storedFire{payload: payload, version: version, results: results.list, failed: true, err: fireErr}
+	}
+
+	if version, err = store.Save(ctx, id, payload, version); err != nil {
+		return storedFire{payload: payload, err: err}
+	}
+
+	if recorder, ok := store.(plinko.TransitionRecorder); ok {
+		if err = recorder.RecordTransitions(ctx, id, version, results.list); err != nil {
+			return storedFire{payload: payload, err: err}
+		}
+	}
+
+	return storedFire{payload: payload, version: version, results: results.list, err: fireErr}
+}
+
+func recordFailedTransitions(ctx context.Context, store plinko.Store, id string, run storedFire, err error) error {
+	recorder, ok := store.(plinko.TransitionRecorder)
+	if !ok || len(run.results) == 0 {
+		return err
+	}
+
+	if recErr := recorder.RecordTransitions(ctx, id, run.version, run.results); recErr != nil {
+		return fmt.Errorf("%w (recording failed transitions: %v)", err, recErr)
+	}
+
+	return err
+}
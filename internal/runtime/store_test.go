@@ -0,0 +1,173 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/store/memory"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func (p *testPayload) Clone() plinko.Payload {
+	c := *p
+	return &c
+}
+
+// racingStore advances the stored version between Load and Save, simulating another process
+// updating the same payload.
+type racingStore struct {
+	*memory.Store
+}
+
+func (s racingStore) Load(ctx context.Context, id string) (plinko.Payload, int64, error) {
+	payload, version, err := s.Store.Load(ctx, id)
+	if err == nil {
+		_, err = s.Store.Save(ctx, id, &testPayload{state: payload.GetState()}, version)
+	}
+
+	return payload, version, err
+}
+
+func createStoreDefinition(store plinko.Store) plinko.PlinkoDefinition {
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.Store = store
+
+	p.Configure(Created).
+		Permit(Open, Opened).
+		OnExit(TransitionFn(false))
+
+	p.Configure(Opened)
+
+	return p
+}
+
+func TestFireByID(t *testing.T) {
+	store := memory.NewStore()
+	psm := createStoreDefinition(store).Compile().StateMachine
+
+	_, err := store.Save(context.TODO(), "order-1", &testPayload{state: Created}, 0)
+	assert.Nil(t, err)
+
+	payload, err := psm.FireByID(context.TODO(), "order-1", Open)
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, payload.GetState())
+
+	stored, version, err := store.Load(context.TODO(), "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, Opened, stored.GetState())
+
+	_, err = psm.FireByID(context.TODO(), "order-1", Open)
+	var pte *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &pte))
+
+	_, version, _ = store.Load(context.TODO(), "order-1")
+	assert.Equal(t, int64(2), version)
+
+	_, err = psm.FireByID(context.TODO(), "order-2", Open)
+	var nf *plinkoerror.PlinkoNotFoundError
+	assert.True(t, errors.As(err, &nf))
+}
+
+func TestFireByIDFailureLeavesStoredPayload(t *testing.T) {
+	store := memory.NewStore()
+
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.Store = store
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			p.(*testPayload).state = t.GetDestination()
+			return p, errors.New("inventory unavailable")
+		})
+
+	psm := p.Compile().StateMachine
+
+	_, err := store.Save(context.TODO(), "order-1", &testPayload{state: Created}, 0)
+	assert.Nil(t, err)
+
+	_, err = psm.FireByID(context.TODO(), "order-1", Open)
+	assert.EqualError(t, err, "inventory unavailable")
+
+	stored, version, err := store.Load(context.TODO(), "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), version)
+	assert.Equal(t, Created, stored.GetState())
+}
+
+func TestFireByIDSavesOnlyWhenFollowUpTriggersSucceed(t *testing.T) {
+	store := memory.NewStore()
+	journal := &recordingJournal{}
+
+	p := createPlinkoDefinition()
+	p.(*PlinkoDefinition).Config.Store = store
+	p.(*PlinkoDefinition).Config.Journal = journal
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			p.(*testPayload).state = t.GetDestination()
+			plinko.EnqueueTrigger(ctx, Claim)
+			return p, nil
+		}).
+		Permit(Claim, Claimed)
+
+	p.Configure(Claimed).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, errors.New("claim rejected")
+		})
+
+	psm := p.Compile().StateMachine
+
+	_, err := store.Save(context.TODO(), "order-1", &testPayload{state: Created}, 0)
+	assert.Nil(t, err)
+
+	_, err = psm.FireByID(context.TODO(), "order-1", Open)
+	assert.EqualError(t, err, "claim rejected")
+
+	// the transition to Opened completed and was journaled, but nothing is saved
+	assert.Equal(t, 1, len(journal.entries))
+	assert.Equal(t, Opened, journal.entries[0].Destination)
+
+	stored, version, err := store.Load(context.TODO(), "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), version)
+	assert.Equal(t, Created, stored.GetState())
+}
+
+func TestFireByIDConflict(t *testing.T) {
+	store := racingStore{memory.NewStore()}
+	psm := createStoreDefinition(store).Compile().StateMachine
+
+	_, err := store.Save(context.TODO(), "order-1", &testPayload{state: Created}, 0)
+	assert.Nil(t, err)
+
+	_, err = psm.FireByID(context.TODO(), "order-1", Open)
+
+	var ce *plinkoerror.PlinkoConflictError
+	assert.True(t, errors.As(err, &ce))
+	assert.Equal(t, "order-1", ce.ID)
+	assert.Equal(t, int64(1), ce.ExpectedVersion)
+	assert.Equal(t, int64(2), ce.ActualVersion)
+}
+
+func TestFireByIDWithoutStore(t *testing.T) {
+	psm := createStoreDefinition(nil).Compile().StateMachine
+
+	_, err := psm.FireByID(context.TODO(), "order-1", Open)
+	assert.Equal(t, errNoStore, err)
+}
@@ -0,0 +1,57 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"github.com/shipt/plinko"
+)
+
+// StatesWithTag returns the states declared with the tag, in the order they were configured.
+func (psm plinkoStateMachine) StatesWithTag(tag string) []plinko.State {
+	var states []plinko.State
+
+	for _, sd := range psm.pd.Abs.StateDefinitions {
+		if hasTag(sd.info, tag) {
+			states = append(states, sd.State)
+		}
+	}
+
+	return states
+}
+
+// StateInfo returns a copy of the configuration of the state, and false when the state is not
+// defined.
+func (psm plinkoStateMachine) StateInfo(state plinko.State) (plinko.StateConfig, bool) {
+	sd, ok := (*psm.pd.States)[state]
+	if !ok {
+		return plinko.StateConfig{}, false
+	}
+
+	return copyStateConfig(sd.info), true
+}
+
+// HasTag reports if the state of the payload, or one of the composite states it is nested
+// within, was declared with the tag.
+func (psm plinkoStateMachine) HasTag(payload plinko.Payload, tag string) bool {
+	for _, sd := range psm.pd.lineage((*psm.pd.States)[payload.GetState()]) {
+		if hasTag(sd.info, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasTag(info plinko.StateConfig, tag string) bool {
+	for _, t := range info.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
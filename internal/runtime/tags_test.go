@@ -0,0 +1,64 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+func withTags(tags ...string) plinko.StateOption {
+	return func(c *plinko.StateConfig) {
+		c.Tags = append(c.Tags, tags...)
+	}
+}
+
+func TestStateQueries(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created, withTags("cancellable"), func(c *plinko.StateConfig) {
+		c.Metadata = map[string]interface{}{"colour": "blue"}
+	}).
+		Permit(Open, InProgress)
+
+	p.Configure(InProgress, withTags("cancellable", "active")).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Picking, func(c *plinko.StateConfig) {
+		c.Parent = InProgress
+		c.Initial = true
+	})
+
+	p.Configure(Canceled)
+
+	psm := p.Compile().StateMachine
+
+	assert.Equal(t, []plinko.State{Created, InProgress}, psm.StatesWithTag("cancellable"))
+	assert.Nil(t, psm.StatesWithTag("unknown"))
+
+	info, ok := psm.StateInfo(Created)
+	assert.True(t, ok)
+	assert.Equal(t, "blue", info.Metadata["colour"])
+
+	// the returned configuration is a copy
+	info.Tags[0] = "archived"
+	info.Metadata["colour"] = "red"
+
+	info, _ = psm.StateInfo(Created)
+	assert.Equal(t, []string{"cancellable"}, info.Tags)
+	assert.Equal(t, "blue", info.Metadata["colour"])
+	assert.Equal(t, []plinko.State{Created, InProgress}, psm.StatesWithTag("cancellable"))
+
+	_, ok = psm.StateInfo(Opened)
+	assert.False(t, ok)
+
+	assert.True(t, psm.HasTag(&testPayload{state: Picking}, "active"))
+	assert.False(t, psm.HasTag(&testPayload{state: Canceled}, "cancellable"))
+	assert.False(t, psm.HasTag(&testPayload{state: Opened}, "cancellable"))
+}
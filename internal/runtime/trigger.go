@@ -25,8 +25,24 @@ func (psm plinkoStateMachine) EnumerateActiveTriggers(payload plinko.Payload) ([
 	}
 
 	keys := make([]plinko.Trigger, 0, len(sd2.Triggers))
-	for k := range sd2.Triggers {
-		keys = append(keys, k)
+	seen := map[plinko.Trigger]bool{}
+	add := func(k plinko.Trigger) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+
+	if sd2.info.Parallel {
+		for _, k := range psm.regionTriggers(payload, sd2) {
+			add(k)
+		}
+	}
+
+	for _, sd := range psm.pd.lineage(sd2) {
+		for k := range sd.Triggers {
+			add(k)
+		}
 	}
 
 	return keys, nil
@@ -34,6 +50,16 @@ func (psm plinkoStateMachine) EnumerateActiveTriggers(payload plinko.Payload) ([
 }
 
 func (psm plinkoStateMachine) CanFire(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) error {
+	_, err := psm.canFire(plinko.WithCanFire(ctx), payload, trigger)
+
+	return err
+}
+
+func (psm plinkoStateMachine) canFireTransition(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, error) {
+	return payload, psm.evaluateCanFire(ctx, payload, trigger)
+}
+
+func (psm plinkoStateMachine) evaluateCanFire(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) error {
 	state := payload.GetState()
 	sd2 := (*psm.pd.States)[state]
 
@@ -41,11 +67,27 @@ func (psm plinkoStateMachine) CanFire(ctx context.Context, payload plinko.Payloa
 		return plinkoerror.CreatePlinkoStateError(state, fmt.Sprintf("State '%s' not defined", state))
 	}
 
-	triggerData := sd2.Triggers[trigger]
+	if sd2.info.Parallel && psm.canFireRegion(ctx, payload, sd2, trigger) {
+		return nil
+	}
+
+	// a deferred trigger is accepted by Fire, which records it to be replayed later
+	triggerData, deferred := psm.pd.resolveTrigger(sd2, trigger)
+	if deferred {
+		if _, ok := payload.(plinko.DeferredTriggerStore); !ok {
+			return plinkoerror.CreatePlinkoTriggerError(trigger, fmt.Sprintf("Trigger '%s' is deferred by state %s, but the payload does not implement DeferredTriggerStore", trigger, state))
+		}
+		return nil
+	}
+
 	if triggerData == nil {
 		return plinkoerror.CreatePlinkoTriggerError(trigger, fmt.Sprintf("Triggers '%s' not defined for state '%s'", trigger, state))
 	}
 
+	if triggerData.Config.Join && !psm.regionsFinal(payload, sd2) {
+		return plinkoerror.CreatePlinkoTriggerError(trigger, fmt.Sprintf("Join Trigger '%s' requires every region of state '%s' to be in a final state", trigger, state))
+	}
+
 	if triggerData.Predicate != nil {
 		return triggerData.Predicate(ctx, payload, sideeffects.TransitionDef{
 			Destination: triggerData.DestinationState,
@@ -58,6 +100,58 @@ func (psm plinkoStateMachine) CanFire(ctx context.Context, payload plinko.Payloa
 }
 
 func (psm plinkoStateMachine) Fire(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, error) {
+	err := psm.withEntityLock(ctx, entityID(payload), func(ctx context.Context) error {
+		var err error
+		payload, err = psm.fire(ctx, payload, trigger)
+
+		return err
+	})
+
+	return payload, err
+}
+
+func (psm plinkoStateMachine) FireWithResults(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, []plinko.TransitionResult, error) {
+	results := &transitionResults{}
+	payload, err := psm.Fire(withTransitionResults(ctx, results), payload, trigger)
+
+	return payload, results.list, err
+}
+
+// fireTransition runs the transition for the trigger and then drains the follow-up triggers
+// enqueued by its operations, run-to-completion style.  Every transition, queued or not, runs
+// through the middleware.
+func (psm plinkoStateMachine) fireTransition(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, error) {
+	queue := &triggerQueue{}
+	ctx = plinko.WithTriggerQueue(ctx, queue)
+
+	payload, err := psm.step(ctx, payload, trigger)
+
+	maxDepth := psm.maxTriggerQueueDepth()
+	for depth := 1; err == nil; depth++ {
+		next, ok := queue.dequeue()
+		if !ok {
+			break
+		}
+
+		if depth > maxDepth {
+			return payload, plinkoerror.CreatePlinkoQueueDepthError(next, maxDepth, fmt.Sprintf("Trigger '%s' exceeds the maximum of %d queued triggers for a single fire", next, maxDepth))
+		}
+
+		payload, err = psm.step(ctx, payload, next)
+	}
+
+	return payload, err
+}
+
+func (psm plinkoStateMachine) maxTriggerQueueDepth() int {
+	if psm.pd.Config.MaxTriggerQueueDepth <= 0 {
+		return plinko.DefaultMaxTriggerQueueDepth
+	}
+
+	return psm.pd.Config.MaxTriggerQueueDepth
+}
+
+func (psm plinkoStateMachine) transition(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, error) {
 	start := time.Now()
 	state := payload.GetState()
 	sd2 := (*psm.pd.States)[state]
@@ -66,13 +160,35 @@ func (psm plinkoStateMachine) Fire(ctx context.Context, payload plinko.Payload,
 		return payload, plinkoerror.CreatePlinkoStateError(state, fmt.Sprintf("State not found in definition of states: %s", state))
 	}
 
-	triggerData := sd2.Triggers[trigger]
+	if sd2.info.Parallel {
+		if payload, handled, err := psm.regionTransition(ctx, payload, sd2, trigger); handled {
+			if err == nil {
+				psm.replayDeferredTrigger(ctx, payload)
+			}
+
+			return payload, err
+		}
+	}
+
+	triggerData, deferred := psm.pd.resolveTrigger(sd2, trigger)
+
+	if deferred {
+		return deferTrigger(payload, trigger)
+	}
 
 	if triggerData == nil {
 		return payload, plinkoerror.CreatePlinkoTriggerError(trigger, fmt.Sprintf("Trigger '%s' not found in definition for state: %s", trigger, state))
 	}
 
-	destinationState := (*psm.pd.States)[triggerData.DestinationState]
+	destination, err := psm.resolveDestination(ctx, payload, triggerData)
+	if err != nil {
+		return payload, err
+	}
+
+	destinationState := (*psm.pd.States)[destination]
+	if destinationState == nil {
+		return payload, plinkoerror.CreatePlinkoStateError(destination, fmt.Sprintf("State not found in definition of states: %s", destination))
+	}
 
 	td := &sideeffects.TransitionDef{
 		Source:      state,
@@ -82,46 +198,151 @@ func (psm plinkoStateMachine) Fire(ctx context.Context, payload plinko.Payload,
 
 	if triggerData.Predicate != nil {
 		if err := triggerData.Predicate(ctx, payload, td); err != nil {
-			return payload, plinkoerror.CreatePlinkoTriggerError(trigger, fmt.Sprintf("Conditional Trigger '%s' conditions not met for state: %s", trigger, state))
+			return payload, plinkoerror.CreatePlinkoGuardError(trigger, err, fmt.Sprintf("Conditional Trigger '%s' conditions not met for state: %s", trigger, state))
 		}
 	}
 
-	sideeffects.Dispatch(ctx, plinko.BeforeTransition, psm.pd.SideEffects, payload, td, time.Since(start).Milliseconds())
+	if triggerData.Config.Join && !psm.regionsFinal(payload, sd2) {
+		return payload, plinkoerror.CreatePlinkoTriggerError(trigger, fmt.Sprintf("Join Trigger '%s' requires every region of state %s to be in a final state", trigger, state))
+	}
+
+	payload, err = psm.runTransition(ctx, payload, td, sd2, destinationState, nil, start)
+	if err != nil {
+		return payload, err
+	}
 
-	payload, err := sd2.Callbacks.ExecuteExitChain(ctx, payload, td)
+	psm.replayDeferredTrigger(ctx, payload)
+
+	return payload, nil
+}
+
+// runTransition executes the exit and entry operations and raises the side effects for a
+// transition whose trigger has been resolved and whose guard has passed.  The region is set
+// when the transition takes place within an orthogonal region of a parallel state.
+func (psm plinkoStateMachine) runTransition(ctx context.Context, payload plinko.Payload, td *sideeffects.TransitionDef, source, destination, region *InternalStateDefinition, start time.Time) (plinko.Payload, error) {
+	sideeffects.Dispatch(ctx, plinko.BeforeTransition, psm.sideEffects.Lookup(td), payload, td, time.Since(start).Milliseconds())
+
+	ctx, trace := psm.withOperationTrace(ctx)
+	snapshot := psm.snapshot(payload)
+	origin := payload.GetState()
+	regions := regionStates(payload, region)
+
+	exiting, entering := psm.pd.transitionPath(source, destination)
+	if region == nil {
+		exiting = psm.withRegionExits(payload, exiting)
+	}
+
+	// routing to the failure state follows a transition that has already run the exit operations
+	failedState := exiting[0]
+	var err error
+	if !routingFailure(ctx) {
+		payload, failedState, err = psm.executeExit(ctx, exiting, payload, td)
+	}
+	if err == nil {
+		err = psm.afterExit(ctx, payload, exiting, source)
+	}
 
 	if err != nil {
-		payload, td, errSub := sd2.Callbacks.ExecuteErrorChain(ctx, payload, td, err, time.Since(start).Milliseconds())
+		ctx, payload := rollback(ctx, payload, snapshot)
+		payload = restoreRegions(payload, regions)
+		payload, td, errSub := psm.executeError(ctx, failedState, payload, td, err, time.Since(start).Milliseconds())
 
 		if errSub != nil {
 			// this ensures that the error condition is trapped and not overriden to the caller of the trigger function
 			err = errSub
 		}
-		sideeffects.Dispatch(ctx, plinko.BetweenStates, psm.pd.SideEffects, payload, td, time.Since(start).Milliseconds())
-		return payload, err
+		sideeffects.Dispatch(ctx, plinko.BetweenStates, psm.sideEffects.Lookup(td), payload, td, time.Since(start).Milliseconds())
+		recordResult(ctx, td, err)
+		return psm.classifyFailure(ctx, payload, td, source, region, err, start)
 	}
 
-	sideeffects.Dispatch(ctx, plinko.BetweenStates, psm.pd.SideEffects, payload, td, time.Since(start).Milliseconds())
+	sideeffects.Dispatch(ctx, plinko.BetweenStates, psm.sideEffects.Lookup(td), payload, td, time.Since(start).Milliseconds())
+
+	payload = assignState(payload, destination, region)
+
+	payload, failedState, err = psm.executeEntry(ctx, entering, payload, td)
+	if err == nil {
+		payload, err = psm.afterEntry(ctx, payload, destination, region, td)
+	}
+	if err == nil {
+		err = verifyState(payload, destination, region)
+	}
+	if err == nil {
+		err = psm.checkInvariants(ctx, payload, destination)
+	}
 
-	payload, err = destinationState.Callbacks.ExecuteEntryChain(ctx, payload, td)
 	if err != nil {
 		var errSub error
 
-		payload, mtd, errSub := destinationState.Callbacks.ExecuteErrorChain(ctx, payload, td, err, time.Since(start).Milliseconds())
-		_ = &sideeffects.TransitionDef{
-			Source:      mtd.GetSource(),
-			Destination: mtd.GetDestination(),
-			Trigger:     mtd.GetTrigger(),
-		}
+		ctx, payload := rollback(ctx, payload, snapshot)
+		payload = restoreState(payload, origin, region)
+		payload = restoreRegions(payload, regions)
+		payload, mtd, errSub := psm.executeError(ctx, failedState, payload, td, err, time.Since(start).Milliseconds())
 
 		if errSub != nil {
 			err = errSub
 		}
 
-		return payload, err
+		recordResult(ctx, mtd, err)
+		return psm.classifyFailure(ctx, payload, mtd, source, region, err, start)
 	}
 
-	sideeffects.Dispatch(ctx, plinko.AfterTransition, psm.pd.SideEffects, payload, td, time.Since(start).Milliseconds())
+	complete := func() error {
+		sideeffects.Dispatch(ctx, plinko.AfterTransition, psm.sideEffects.Lookup(td), payload, td, time.Since(start).Milliseconds())
 
-	return payload, nil
+		// the transition has taken place even when it cannot be journaled, but the caller is told so
+		err := psm.recordJournal(ctx, payload, td, trace, start)
+		recordResult(ctx, td, err)
+
+		return err
+	}
+
+	if hold(ctx, complete) {
+		return payload, nil
+	}
+
+	return payload, complete()
+}
+
+// afterExit runs once the states being left have executed their exit operations.
+func (psm plinkoStateMachine) afterExit(ctx context.Context, payload plinko.Payload, exiting []*InternalStateDefinition, source *InternalStateDefinition) error {
+	psm.clearRegions(payload, source)
+
+	return psm.recordHistory(ctx, payload, exiting, source.State)
+}
+
+// afterEntry runs once the states being entered have executed their entry operations.
+func (psm plinkoStateMachine) afterEntry(ctx context.Context, payload plinko.Payload, destination, region *InternalStateDefinition, td *sideeffects.TransitionDef) (plinko.Payload, error) {
+	if region != nil {
+		payload.(plinko.RegionalPayload).SetRegionState(region.State, destination.State)
+
+		return payload, nil
+	}
+
+	return psm.enterRegions(ctx, payload, destination, td)
+}
+
+// executeExitChains runs the exit operations of the states being left, innermost first.  On
+// failure it returns the state whose operation failed, so its error chain can be run.
+func (psm plinkoStateMachine) executeExitChains(ctx context.Context, states []*InternalStateDefinition, payload plinko.Payload, td *sideeffects.TransitionDef) (plinko.Payload, *InternalStateDefinition, error) {
+	var err error
+	for _, sd := range states {
+		if payload, err = psm.callbacksOf(sd).ExecuteExitChain(ctx, payload, td); err != nil {
+			return payload, sd, err
+		}
+	}
+
+	return payload, states[0], nil
+}
+
+// executeEntryChains runs the entry operations of the states being entered, outermost first.
+func (psm plinkoStateMachine) executeEntryChains(ctx context.Context, states []*InternalStateDefinition, payload plinko.Payload, td *sideeffects.TransitionDef) (plinko.Payload, *InternalStateDefinition, error) {
+	var err error
+	for _, sd := range states {
+		if payload, err = psm.callbacksOf(sd).ExecuteEntryChain(ctx, payload, td); err != nil {
+			return payload, sd, err
+		}
+	}
+
+	return payload, states[len(states)-1], nil
 }
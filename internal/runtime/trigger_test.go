@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/internal/composition"
 	"github.com/shipt/plinko/plinkoerror"
 	"github.com/stretchr/testify/assert"
 )
@@ -42,10 +43,15 @@ func (p *testPayload) GetState() plinko.State {
 	return p.state
 }
 
-func createPlinkoDefinition() plinko.PlinkoDefinition {
+func createPlinkoDefinition(opts ...plinko.DefinitionOption) plinko.PlinkoDefinition {
 	stateMap := make(map[plinko.State]*InternalStateDefinition)
 	p := PlinkoDefinition{
 		States: &stateMap,
+		Hooks:  &composition.CallbackDefinitions{},
+	}
+
+	for _, opt := range opts {
+		opt(&p.Config)
 	}
 
 	p.Abs = AbstractSyntax{}
@@ -363,3 +369,57 @@ func TestEnumerateTriggers(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, 0, len(triggers))
 }
+
+func TestFireWithMiddleware(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	var calls []string
+	p.Use(func(next plinko.FireFunc) plinko.FireFunc {
+		return func(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, error) {
+			calls = append(calls, "outer")
+			return next(ctx, payload, trigger)
+		}
+	}, func(next plinko.FireFunc) plinko.FireFunc {
+		return func(ctx context.Context, payload plinko.Payload, trigger plinko.Trigger) (plinko.Payload, error) {
+			if plinko.IsCanFire(ctx) {
+				calls = append(calls, "inner-canfire")
+			} else {
+				calls = append(calls, "inner-fire")
+			}
+
+			if trigger == Cancel {
+				return payload, errors.New("not authorized")
+			}
+
+			return next(ctx, payload, trigger)
+		}
+	})
+
+	p.Configure(Created).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled).
+		OnExit(TransitionFn(false))
+
+	p.Configure(Opened)
+	p.Configure(Canceled)
+
+	psm := p.Compile().StateMachine
+
+	payload := &testPayload{state: Created}
+
+	assert.Nil(t, psm.CanFire(context.TODO(), payload, Open))
+	assert.Equal(t, []string{"outer", "inner-canfire"}, calls)
+
+	calls = nil
+	assert.EqualError(t, psm.CanFire(context.TODO(), payload, Cancel), "not authorized")
+
+	calls = nil
+	_, err := psm.Fire(context.TODO(), payload, Cancel)
+	assert.EqualError(t, err, "not authorized")
+	assert.Equal(t, Created, payload.GetState())
+	assert.Equal(t, []string{"outer", "inner-fire"}, calls)
+
+	pr, err := psm.Fire(context.TODO(), payload, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, pr.GetState())
+}
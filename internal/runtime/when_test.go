@@ -0,0 +1,106 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func IsConditionMet(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) bool {
+	return p.(*testPayload).condition
+}
+
+func TestFireWithPermitWhen(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		PermitWhen(IsConditionMet, Open, Opened).
+		PermitReentryWhen(IsConditionMet, Cancel)
+
+	p.Configure(Opened).
+		OnEntry(TransitionFn(false))
+
+	psm := p.Compile().StateMachine
+
+	payload := &testPayload{state: Created}
+	assert.NotNil(t, psm.CanFire(context.TODO(), payload, Open))
+
+	pr, err := psm.Fire(context.TODO(), payload, Open)
+	assert.Equal(t, "Conditional Trigger 'Open' conditions not met for state: Created", err.Error())
+	assert.Equal(t, Created, pr.GetState())
+
+	var pte *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &pte))
+	assert.Equal(t, "guard 'IsConditionMet' not satisfied", pte.Cause.Error())
+
+	_, err = psm.Fire(context.TODO(), payload, Cancel)
+	assert.NotNil(t, err)
+
+	payload.condition = true
+	pr, err = psm.Fire(context.TODO(), payload, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, Created, pr.GetState())
+
+	pr, err = psm.Fire(context.TODO(), payload, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, pr.GetState())
+}
+
+func TestFireWithOperationsWhen(t *testing.T) {
+	var ran []string
+
+	record := func(name string) plinko.Operation {
+		return func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			ran = append(ran, name)
+			return p, nil
+		}
+	}
+
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		OnExitWhen(IsConditionMet, record("exit")).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntryWhen(IsConditionMet, record("entry")).
+		OnEntry(record("always"))
+
+	psm := p.Compile().StateMachine
+
+	_, err := psm.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"always"}, ran)
+
+	ran = nil
+	_, err = psm.Fire(context.TODO(), &testPayload{state: Created, condition: true}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"exit", "entry", "always"}, ran)
+}
+
+func TestCompileWithNilWhenGuard(t *testing.T) {
+	p := createPlinkoDefinition()
+
+	p.Configure(Created).
+		PermitWhen(nil, Open, Opened).
+		PermitReentryGuard(nil, Cancel).
+		OnExitWhen(nil, TransitionFn(false))
+
+	p.Configure(Opened)
+
+	co := p.Compile()
+
+	assert.Contains(t, co.Messages, plinko.CompilerMessage{CompileMessage: plinko.CompileError, Message: "State 'Created' declares Trigger 'Open' with a nil guard."})
+	assert.Contains(t, co.Messages, plinko.CompilerMessage{CompileMessage: plinko.CompileError, Message: "State 'Created' declares Trigger 'Cancel' with a nil guard."})
+	assert.Contains(t, co.Messages, plinko.CompilerMessage{CompileMessage: plinko.CompileError, Message: "State 'Created' declares an exit operation with a nil guard."})
+}
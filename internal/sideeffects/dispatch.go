@@ -13,12 +13,99 @@ import (
 )
 
 // AllowAllSideEffects is a convenience constant for registering a global
-const AllowAllSideEffects = plinko.AllowBeforeTransition | plinko.AllowAfterTransition | plinko.AllowBetweenStates
+const AllowAllSideEffects = plinko.AllowBeforeTransition | plinko.AllowAfterTransition | plinko.AllowBetweenStates |
+	plinko.AllowRetryableFailure | plinko.AllowFatalFailure
 
 // SideEffectDefinition holds the callback and filtering characteristics describing when the sideeffect is signaled.
 type SideEffectDefinition struct {
 	SideEffect plinko.SideEffect
 	Filter     plinko.SideEffectFilter
+	Config     plinko.SideEffectConfig
+}
+
+// Matches reports if the state and trigger filters of the definition accept the transition.
+func (sed SideEffectDefinition) Matches(source, destination plinko.State, trigger plinko.Trigger) bool {
+	return containsState(sed.Config.Sources, source) &&
+		containsState(sed.Config.Destinations, destination) &&
+		containsTrigger(sed.Config.Triggers, trigger)
+}
+
+func containsState(states []plinko.State, state plinko.State) bool {
+	if len(states) == 0 {
+		return true
+	}
+
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsTrigger(triggers []plinko.Trigger, trigger plinko.Trigger) bool {
+	if len(triggers) == 0 {
+		return true
+	}
+
+	for _, t := range triggers {
+		if t == trigger {
+			return true
+		}
+	}
+
+	return false
+}
+
+type transitionKey struct {
+	source      plinko.State
+	destination plinko.State
+	trigger     plinko.Trigger
+}
+
+// Index holds the side effects that apply to each declared transition so a dispatch
+// only visits the relevant handlers.  The index is built once at compile time and is
+// read-only afterwards, making it safe to share across goroutines.
+type Index struct {
+	all         []SideEffectDefinition
+	transitions map[transitionKey][]SideEffectDefinition
+}
+
+// NewIndex creates an index over the registered side effects.
+func NewIndex(sideEffects []SideEffectDefinition) *Index {
+	return &Index{
+		all:         sideEffects,
+		transitions: make(map[transitionKey][]SideEffectDefinition),
+	}
+}
+
+// Precompute resolves the side effects for a declared transition.
+func (ix *Index) Precompute(source, destination plinko.State, trigger plinko.Trigger) {
+	ix.transitions[transitionKey{source: source, destination: destination, trigger: trigger}] = filter(ix.all, source, destination, trigger)
+}
+
+// Lookup returns the side effects for the transition.  Transitions that were not
+// precomputed, such as those redirected by an error operation, are filtered on demand.
+func (ix *Index) Lookup(transitionInfo plinko.TransitionInfo) []SideEffectDefinition {
+	source, destination, trigger := transitionInfo.GetSource(), transitionInfo.GetDestination(), transitionInfo.GetTrigger()
+
+	if sideEffects, ok := ix.transitions[transitionKey{source: source, destination: destination, trigger: trigger}]; ok {
+		return sideEffects
+	}
+
+	return filter(ix.all, source, destination, trigger)
+}
+
+func filter(sideEffects []SideEffectDefinition, source, destination plinko.State, trigger plinko.Trigger) []SideEffectDefinition {
+	var result []SideEffectDefinition
+	for _, sed := range sideEffects {
+		if sed.Matches(source, destination, trigger) {
+			result = append(result, sed)
+		}
+	}
+
+	return result
 }
 
 func getFilterDefinition(stateAction plinko.StateAction) plinko.SideEffectFilter {
@@ -30,6 +117,10 @@ func getFilterDefinition(stateAction plinko.StateAction) plinko.SideEffectFilter
 		return plinko.AllowBetweenStates
 	case plinko.AfterTransition:
 		return plinko.AllowAfterTransition
+	case plinko.RetryableFailure:
+		return plinko.AllowRetryableFailure
+	case plinko.FatalFailure:
+		return plinko.AllowFatalFailure
 	}
 
 	return 0
@@ -111,3 +111,45 @@ func TestStateActionToFilterMapping(t *testing.T) {
 	assert.Equal(t, plinko.SideEffectFilter(2), getFilterDefinition(plinko.BetweenStates))
 	assert.Equal(t, plinko.SideEffectFilter(0), getFilterDefinition("unknown"))
 }
+
+func TestSideEffectDefinitionMatches(t *testing.T) {
+	sed := SideEffectDefinition{}
+	assert.True(t, sed.Matches("foo1", "foo2", "foo3"))
+
+	sed.Config = plinko.SideEffectConfig{
+		Sources:      []plinko.State{"foo1"},
+		Destinations: []plinko.State{"foo2", "foo4"},
+		Triggers:     []plinko.Trigger{"foo3"},
+	}
+	assert.True(t, sed.Matches("foo1", "foo2", "foo3"))
+	assert.True(t, sed.Matches("foo1", "foo4", "foo3"))
+	assert.False(t, sed.Matches("foo2", "foo2", "foo3"))
+	assert.False(t, sed.Matches("foo1", "foo1", "foo3"))
+	assert.False(t, sed.Matches("foo1", "foo2", "foo1"))
+}
+
+func TestIndexLookup(t *testing.T) {
+	var effects []SideEffectDefinition
+	callCount := 0
+	sideEffect := func(_ context.Context, sa plinko.StateAction, p plinko.Payload, ti plinko.TransitionInfo, elapsed int64) {
+		callCount++
+	}
+
+	effects = append(effects, SideEffectDefinition{Filter: AllowAllSideEffects, SideEffect: sideEffect})
+	effects = append(effects, SideEffectDefinition{Filter: AllowAllSideEffects, SideEffect: sideEffect, Config: plinko.SideEffectConfig{Destinations: []plinko.State{"Delivered"}}})
+	effects = append(effects, SideEffectDefinition{Filter: AllowAllSideEffects, SideEffect: sideEffect, Config: plinko.SideEffectConfig{Triggers: []plinko.Trigger{"Cancel"}}})
+
+	ix := NewIndex(effects)
+	ix.Precompute("Opened", "Delivered", "Deliver")
+	ix.Precompute("Opened", "Canceled", "Cancel")
+
+	assert.Equal(t, 2, len(ix.Lookup(TransitionDef{Source: "Opened", Destination: "Delivered", Trigger: "Deliver"})))
+	assert.Equal(t, 2, len(ix.Lookup(TransitionDef{Source: "Opened", Destination: "Canceled", Trigger: "Cancel"})))
+
+	// a redirected destination is not precomputed, so it is resolved on demand
+	assert.Equal(t, 1, len(ix.Lookup(TransitionDef{Source: "Opened", Destination: "Triage", Trigger: "Deliver"})))
+
+	count := Dispatch(context.TODO(), plinko.AfterTransition, ix.Lookup(TransitionDef{Source: "Opened", Destination: "Delivered", Trigger: "Deliver"}), testPayload{}, TransitionDef{}, 0)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 2, callCount)
+}
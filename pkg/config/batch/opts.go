@@ -0,0 +1,24 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package batch
+
+import "github.com/shipt/plinko"
+
+// WithConcurrency sets how many payloads FireBatch fires at once.
+func WithConcurrency(concurrency int) func(*plinko.BatchConfig) {
+	return func(c *plinko.BatchConfig) {
+		c.Concurrency = concurrency
+	}
+}
+
+// SkipIfCannotFire skips the payloads for which CanFire fails, rather than reporting them as
+// failures.
+func SkipIfCannotFire() func(*plinko.BatchConfig) {
+	return func(c *plinko.BatchConfig) {
+		c.SkipIfCannotFire = true
+	}
+}
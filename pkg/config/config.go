@@ -2,17 +2,32 @@ package config
 
 import (
 	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/internal/composition"
 	"github.com/shipt/plinko/internal/runtime"
 )
 
 // CreatePlinkoDefinition ... creates a new structure used in defining the state machine.
-func CreatePlinkoDefinition() plinko.PlinkoDefinition {
+func CreatePlinkoDefinition(opts ...plinko.DefinitionOption) plinko.PlinkoDefinition {
 	stateMap := make(map[plinko.State]*runtime.InternalStateDefinition)
 	p := runtime.PlinkoDefinition{
 		States: &stateMap,
+		Config: newDefinitionConfig(opts...),
+		Hooks:  &composition.CallbackDefinitions{},
 	}
 
 	p.Abs = runtime.AbstractSyntax{}
 
 	return &p
 }
+
+func newDefinitionConfig(opts ...plinko.DefinitionOption) plinko.DefinitionConfig {
+	c := plinko.DefinitionConfig{
+		MaxTriggerQueueDepth: plinko.DefaultMaxTriggerQueueDepth,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
@@ -8,7 +8,12 @@ import (
 
 	"github.com/shipt/plinko"
 	"github.com/shipt/plinko/internal/runtime"
+	"github.com/shipt/plinko/pkg/config/batch"
+	"github.com/shipt/plinko/pkg/config/definition"
 	"github.com/shipt/plinko/pkg/config/operation"
+	"github.com/shipt/plinko/pkg/config/sideeffect"
+	"github.com/shipt/plinko/pkg/config/state"
+	"github.com/shipt/plinko/pkg/config/trigger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -306,6 +311,49 @@ func TestStateMachineSideEffectFiltering(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestStateMachineSideEffectStateFiltering(t *testing.T) {
+	p := CreatePlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled)
+
+	var entered, exited, triggered []plinko.StateAction
+	p.FilteredSideEffect(plinko.AllowAfterTransition, func(_ context.Context, sa plinko.StateAction, payload plinko.Payload, ti plinko.TransitionInfo, elapsed int64) {
+		assert.Equal(t, Canceled, ti.GetDestination())
+		entered = append(entered, sa)
+	}, sideeffect.OnEnter(Canceled))
+	p.SideEffect(func(_ context.Context, sa plinko.StateAction, payload plinko.Payload, ti plinko.TransitionInfo, elapsed int64) {
+		assert.Equal(t, Opened, ti.GetSource())
+		exited = append(exited, sa)
+	}, sideeffect.OnExit(Opened))
+	p.SideEffect(func(_ context.Context, sa plinko.StateAction, payload plinko.Payload, ti plinko.TransitionInfo, elapsed int64) {
+		assert.Equal(t, Open, ti.GetTrigger())
+		triggered = append(triggered, sa)
+	}, sideeffect.OnTrigger(Open))
+
+	psm := p.Compile().StateMachine
+
+	payload := &testPayload{state: Created}
+	_, err := psm.Fire(context.TODO(), payload, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entered))
+	assert.Equal(t, 0, len(exited))
+	assert.Equal(t, 3, len(triggered))
+
+	payload = &testPayload{state: Opened}
+	_, err = psm.Fire(context.TODO(), payload, Cancel)
+	assert.Nil(t, err)
+	assert.Equal(t, []plinko.StateAction{plinko.AfterTransition}, entered)
+	assert.Equal(t, 3, len(exited))
+	assert.Equal(t, 3, len(triggered))
+}
+
 func TestCanFire(t *testing.T) {
 	p := CreatePlinkoDefinition()
 
@@ -535,3 +583,201 @@ func TestStateMachinePanicSuppression(t *testing.T) {
 	require.Error(t, e)
 	assert.Contains(t, e.Error(), "overridden function name")
 }
+
+func TestTriggerQueueDepthOption(t *testing.T) {
+	p := CreatePlinkoDefinition(definition.WithMaxTriggerQueueDepth(2))
+
+	p.Configure(Created).
+		OnEntry(func(ctx context.Context, pp plinko.Payload, transitionInfo plinko.TransitionInfo) (plinko.Payload, error) {
+			plinko.EnqueueTrigger(ctx, Reinstate)
+			return pp, nil
+		}).
+		PermitReentry(Reinstate)
+
+	psm := p.Compile().StateMachine
+
+	_, results, err := psm.FireWithResults(context.TODO(), &testPayload{state: Created}, Reinstate)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, len(results))
+}
+
+func TestFireBatchOptions(t *testing.T) {
+	p := CreatePlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened)
+
+	psm := p.Compile().StateMachine
+
+	payloads := []plinko.Payload{&testPayload{state: Created}, &testPayload{state: Opened}, &testPayload{state: Created}}
+
+	result, err := psm.FireBatch(context.TODO(), payloads, Open, batch.WithConcurrency(2), batch.SkipIfCannotFire())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Succeeded)
+	assert.Equal(t, 1, result.Skipped)
+	assert.True(t, result.Items[1].Skipped)
+}
+
+func TestTriggerOptions(t *testing.T) {
+	p := CreatePlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened, trigger.WithDescription("Open the order"), trigger.WithTags("customer", "api"), trigger.WithMetadata("icon", "door")).
+		PermitReentry(Submit)
+
+	p.Configure(Opened)
+
+	configs := map[plinko.Trigger]plinko.TriggerConfig{}
+	p.(*runtime.PlinkoDefinition).Edges(func(_, _ plinko.State, name plinko.Trigger, config plinko.TriggerConfig) {
+		configs[name] = config
+	})
+
+	assert.Equal(t, "Open the order", configs[Open].Description)
+	assert.Equal(t, []string{"customer", "api"}, configs[Open].Tags)
+	assert.Equal(t, map[string]interface{}{"icon": "door"}, configs[Open].Metadata)
+	assert.Equal(t, plinko.TriggerConfig{}, configs[Submit])
+}
+
+func TestOperationOrdering(t *testing.T) {
+	var ran []string
+
+	record := func(name string) plinko.Operation {
+		return func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			ran = append(ran, name)
+			return p, nil
+		}
+	}
+
+	p := CreatePlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	opened := p.Configure(Opened)
+	opened.
+		OnEntry(record("Notify"), operation.WithName("Notify"), operation.After("Reserve")).
+		OnEntry(record("Reserve"), operation.WithName("Reserve"), operation.WithDescription("Reserve the inventory"), operation.WithTags("inventory")).
+		OnEntry(record("Audit"), operation.WithName("Audit"), operation.WithPriority(1)).
+		Permit(Cancel, Created)
+
+	co := p.Compile()
+	assert.Empty(t, co.Messages)
+
+	_, err := co.StateMachine.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Audit", "Reserve", "Notify"}, ran)
+
+	chains, ok := co.StateMachine.Operations(Opened)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(chains.Entry))
+	assert.Equal(t, "Reserve", chains.Entry[1].Name)
+	assert.Equal(t, "Reserve the inventory", chains.Entry[1].Description)
+	assert.Equal(t, []string{"inventory"}, chains.Entry[1].Tags)
+
+	_, ok = co.StateMachine.Operations(Claimed)
+	assert.False(t, ok)
+
+	// operations added after Compile, to a state or to every transition, run in declaration order
+	// until the definition is compiled again
+	opened.OnEntry(record("Late"), operation.WithName("Late"), operation.WithPriority(2))
+	p.OnAnyEntry(record("LateHook"), operation.WithName("LateHook"))
+	p.OnAnyEntry(record("LateFirstHook"), operation.WithName("LateFirstHook"), operation.Before("LateHook"))
+
+	ran = nil
+	_, err = co.StateMachine.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"LateHook", "LateFirstHook", "Notify", "Reserve", "Audit", "Late"}, ran)
+
+	ran = nil
+	_, err = p.Compile().StateMachine.Fire(context.TODO(), &testPayload{state: Created}, Open)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"LateFirstHook", "LateHook", "Late", "Audit", "Reserve", "Notify"}, ran)
+}
+
+func TestOperationOrderingCycle(t *testing.T) {
+	p := CreatePlinkoDefinition()
+
+	p.Configure(Created).
+		OnExit(exitFunctionForTest, operation.WithName("Release"), operation.Before("Refund")).
+		OnExit(exitFunctionForTest, operation.WithName("Refund"), operation.Before("Release")).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		Permit(Cancel, Created)
+
+	co := p.Compile()
+
+	assert.Equal(t, []plinko.CompilerMessage{
+		{CompileMessage: plinko.CompileError, Message: "State 'Created' orders its exit operations in a cycle: Release, Refund."},
+	}, co.Messages)
+}
+
+func IsOpenable(_ context.Context, _ plinko.Payload, _ plinko.TransitionInfo) error {
+	return nil
+}
+
+func TestDescribe(t *testing.T) {
+	p := CreatePlinkoDefinition()
+
+	p.SideEffect(func(_ context.Context, _ plinko.StateAction, _ plinko.Payload, _ plinko.TransitionInfo, _ int64) {}, sideeffect.OnExit(Created))
+	p.OnAnyError(func(_ context.Context, p plinko.Payload, _ plinko.ModifiableTransitionInfo, err error) (plinko.Payload, error) {
+		return p, err
+	}, operation.WithName("Alert"))
+
+	p.Configure(Created, state.WithTag("new")).
+		OnExit(exitFunctionForTest, operation.WithName("Release")).
+		PermitIf(IsOpenable, Open, Opened).
+		Permit(Cancel, Canceled, trigger.WithDescription("Cancel the order")).
+		Defer(Claim)
+
+	p.Configure(Opened).
+		OnEntry(entryFunctionForTest, operation.WithName("Reserve")).
+		Permit(Claim, Claimed)
+
+	p.Configure(Claimed)
+	p.Configure(Canceled)
+
+	d := p.Compile().StateMachine.Describe()
+
+	assert.Equal(t, 4, len(d.States))
+	assert.Equal(t, Created, d.States[0].State)
+	assert.Equal(t, []string{"new"}, d.States[0].Config.Tags)
+	assert.Equal(t, []plinko.TriggerDescription{
+		{Trigger: Cancel, Destination: Canceled, Config: plinko.TriggerConfig{Description: "Cancel the order"}},
+		{Trigger: Open, Destination: Opened, Guard: "IsOpenable"},
+	}, d.States[0].Triggers)
+	assert.Equal(t, []plinko.Trigger{Claim}, d.States[0].Deferred)
+	assert.Equal(t, "Release", d.States[0].Operations.Exit[0].Name)
+	assert.Equal(t, "Reserve", d.States[1].Operations.Entry[0].Name)
+	assert.Equal(t, "Alert", d.Hooks.Error[0].Name)
+
+	require.Equal(t, 1, len(d.SideEffects))
+	assert.Equal(t, []plinko.State{Created}, d.SideEffects[0].Config.Sources)
+
+	d.States[0].Config.Tags[0] = "changed"
+	assert.Equal(t, []string{"new"}, p.Compile().StateMachine.Describe().States[0].Config.Tags)
+}
+
+func TestHistoryAndJoinTriggerOptions(t *testing.T) {
+	p := CreatePlinkoDefinition()
+
+	p.Configure(Created).
+		PermitToHistory(Open, Opened, trigger.WithDescription("Resume the order")).
+		PermitToDeepHistory(Claim, Opened, trigger.WithTags("resume")).
+		PermitJoin(Cancel, Canceled, trigger.WithMetadata("sla", "1h"))
+
+	p.Configure(Opened)
+	p.Configure(Canceled)
+
+	d := p.Compile().StateMachine.Describe()
+
+	assert.Equal(t, []plinko.TriggerDescription{
+		{Trigger: Cancel, Destination: Canceled, Config: plinko.TriggerConfig{Join: true, Metadata: map[string]interface{}{"sla": "1h"}}},
+		{Trigger: Claim, Destination: Opened, Config: plinko.TriggerConfig{History: plinko.DeepHistory, Tags: []string{"resume"}}},
+		{Trigger: Open, Destination: Opened, Config: plinko.TriggerConfig{History: plinko.ShallowHistory, Description: "Resume the order"}},
+	}, d.States[0].Triggers)
+}
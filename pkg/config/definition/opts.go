@@ -0,0 +1,90 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package definition
+
+import (
+	"time"
+
+	"github.com/shipt/plinko"
+)
+
+// WithMaxTriggerQueueDepth sets how many follow-up triggers a single Fire call processes.
+func WithMaxTriggerQueueDepth(depth int) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.MaxTriggerQueueDepth = depth
+	}
+}
+
+// WithHistoryStore sets the store used to record history for payloads that do not
+// implement plinko.HistoryStore themselves.
+func WithHistoryStore(store plinko.HistoryStore) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.HistoryStore = store
+	}
+}
+
+// WithStore sets the store FireByID loads payloads from and saves them to.
+func WithStore(store plinko.Store) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.Store = store
+	}
+}
+
+// WithJournal sets the journal every transition completed by Fire is recorded to.
+func WithJournal(journal plinko.Journal) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.Journal = journal
+	}
+}
+
+// WithIdempotencyStore sets the store FireIdempotent records outcomes in.
+func WithIdempotencyStore(store plinko.IdempotencyStore) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.IdempotencyStore = store
+	}
+}
+
+// WithLocker sets the locker Fire uses to serialize transitions on payloads that implement
+// plinko.Identifiable.
+func WithLocker(locker plinko.Locker) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.Locker = locker
+	}
+}
+
+// WithLockTimeout bounds how long Fire waits for the lock of an entity.
+func WithLockTimeout(timeout time.Duration) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.LockTimeout = timeout
+	}
+}
+
+// WithRollback makes Fire snapshot payloads that implement plinko.Cloneable before a
+// transition, and roll back to the snapshot when the transition fails.  Payloads that also
+// implement plinko.Restorable are rolled back in place; for others, callers must use the
+// payload returned from Fire, as the one they passed in keeps the failed changes.
+func WithRollback() func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.RollbackOnError = true
+	}
+}
+
+// WithGlobalHookOrder sets whether the operations registered with OnAnyEntry, OnAnyExit and
+// OnAnyError run before or after the operations of the states.
+func WithGlobalHookOrder(order plinko.HookOrder) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.GlobalHookOrder = order
+	}
+}
+
+// WithFailureState sets the state a transition that fails with a plinkoerror.Fatal error routes
+// the payload to.
+func WithFailureState(state plinko.State) func(*plinko.DefinitionConfig) {
+	return func(c *plinko.DefinitionConfig) {
+		c.FailureState = state
+	}
+}
@@ -15,3 +15,40 @@ func WithName(name string) func(*plinko.OperationConfig) {
 		c.Name = name
 	}
 }
+
+// WithDescription describes what the operation does.
+func WithDescription(description string) func(*plinko.OperationConfig) {
+	return func(c *plinko.OperationConfig) {
+		c.Description = description
+	}
+}
+
+// WithTags adds tags to the operation, for grouping or filtering operations.
+func WithTags(tags ...string) func(*plinko.OperationConfig) {
+	return func(c *plinko.OperationConfig) {
+		c.Tags = append(c.Tags, tags...)
+	}
+}
+
+// WithPriority orders the operation among the operations of its chain that are not constrained
+// by Before and After.  Operations with a higher priority run first, and operations with the same
+// priority run in the order they were declared.
+func WithPriority(priority int) func(*plinko.OperationConfig) {
+	return func(c *plinko.OperationConfig) {
+		c.Priority = priority
+	}
+}
+
+// Before runs the operation ahead of the named operation of the same chain.
+func Before(name string) func(*plinko.OperationConfig) {
+	return func(c *plinko.OperationConfig) {
+		c.Before = append(c.Before, name)
+	}
+}
+
+// After runs the operation once the named operation of the same chain has run.
+func After(name string) func(*plinko.OperationConfig) {
+	return func(c *plinko.OperationConfig) {
+		c.After = append(c.After, name)
+	}
+}
@@ -0,0 +1,30 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package sideeffect
+
+import "github.com/shipt/plinko"
+
+// OnEnter limits the side effect to transitions landing in one of the given states.
+func OnEnter(states ...plinko.State) func(*plinko.SideEffectConfig) {
+	return func(c *plinko.SideEffectConfig) {
+		c.Destinations = append(c.Destinations, states...)
+	}
+}
+
+// OnExit limits the side effect to transitions leaving one of the given states.
+func OnExit(states ...plinko.State) func(*plinko.SideEffectConfig) {
+	return func(c *plinko.SideEffectConfig) {
+		c.Sources = append(c.Sources, states...)
+	}
+}
+
+// OnTrigger limits the side effect to transitions raised by one of the given triggers.
+func OnTrigger(triggers ...plinko.Trigger) func(*plinko.SideEffectConfig) {
+	return func(c *plinko.SideEffectConfig) {
+		c.Triggers = append(c.Triggers, triggers...)
+	}
+}
@@ -6,7 +6,11 @@
  */
 package state
 
-import "github.com/shipt/plinko"
+import (
+	"context"
+
+	"github.com/shipt/plinko"
+)
 
 func WithName(name string) func(*plinko.StateConfig) {
 	return func(c *plinko.StateConfig) {
@@ -19,3 +23,60 @@ func WithDescription(description string) func(*plinko.StateConfig) {
 		c.Description = description
 	}
 }
+
+// SubstateOf nests the state within a composite parent state.  A substate inherits the
+// triggers of its parent and is exited and entered along with it.
+func SubstateOf(parent plinko.State) func(*plinko.StateConfig) {
+	return func(c *plinko.StateConfig) {
+		c.Parent = parent
+	}
+}
+
+// Parallel turns the substates of the state into orthogonal regions that are active at the
+// same time while the payload is in the state.
+func Parallel() func(*plinko.StateConfig) {
+	return func(c *plinko.StateConfig) {
+		c.Parallel = true
+	}
+}
+
+// Initial marks the state as the one a region starts in when its parallel state is entered.
+func Initial() func(*plinko.StateConfig) {
+	return func(c *plinko.StateConfig) {
+		c.Initial = true
+	}
+}
+
+// Final marks the state as the end of its region, used to decide when a join transition fires.
+func Final() func(*plinko.StateConfig) {
+	return func(c *plinko.StateConfig) {
+		c.Final = true
+	}
+}
+
+// WithInvariant adds a rule the payload must satisfy while it is in the state, and in its
+// substates.  The rule is checked once a transition into the state has run its entry
+// operations, and by StateMachine.Validate.
+func WithInvariant(name string, check func(context.Context, plinko.Payload) error) func(*plinko.StateConfig) {
+	return func(c *plinko.StateConfig) {
+		c.Invariants = append(c.Invariants, plinko.Invariant{Name: name, Check: check})
+	}
+}
+
+// WithTag adds tags to the state, which StateMachine.StatesWithTag and StateMachine.HasTag query.
+func WithTag(tags ...string) func(*plinko.StateConfig) {
+	return func(c *plinko.StateConfig) {
+		c.Tags = append(c.Tags, tags...)
+	}
+}
+
+// WithMetadata attaches a value to the state under the key, returned by StateMachine.StateInfo.
+func WithMetadata(key string, value interface{}) func(*plinko.StateConfig) {
+	return func(c *plinko.StateConfig) {
+		if c.Metadata == nil {
+			c.Metadata = map[string]interface{}{}
+		}
+
+		c.Metadata[key] = value
+	}
+}
@@ -0,0 +1,34 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package trigger
+
+import "github.com/shipt/plinko"
+
+// WithDescription sets a human-friendly description of the action the trigger represents.
+func WithDescription(description string) func(*plinko.TriggerConfig) {
+	return func(c *plinko.TriggerConfig) {
+		c.Description = description
+	}
+}
+
+// WithTags adds tags to the transition, for grouping or filtering transitions.
+func WithTags(tags ...string) func(*plinko.TriggerConfig) {
+	return func(c *plinko.TriggerConfig) {
+		c.Tags = append(c.Tags, tags...)
+	}
+}
+
+// WithMetadata attaches a value to the transition under the key.
+func WithMetadata(key string, value interface{}) func(*plinko.TriggerConfig) {
+	return func(c *plinko.TriggerConfig) {
+		if c.Metadata == nil {
+			c.Metadata = map[string]interface{}{}
+		}
+
+		c.Metadata[key] = value
+	}
+}
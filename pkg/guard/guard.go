@@ -0,0 +1,216 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package guard combines predicates into guards whose structure is preserved, so definitions
+// can render them on their transitions and failures can explain which part of a guard failed.
+//
+// Guards are handed to a definition with PermitGuard:
+//
+//	p.Configure(Opened).
+//	   PermitGuard(guard.And(guard.Named("IsCancellable", isCancellable), guard.Not(guard.Named("IsFlagged", isFlagged))), Cancel, Canceled)
+//
+// renders the transition as "Cancel [IsCancellable && !IsFlagged]".
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/shipt/plinko"
+)
+
+// Error reports the guard that failed.  For a guard that is the combination of others, it
+// names the innermost named guard responsible.
+type Error struct {
+	Guard string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("guard '%s' failed: %v", e.Guard, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type op int
+
+const (
+	leaf op = iota
+	and
+	or
+	not
+)
+
+// Guard is a predicate that describes itself.  A guard is either a named predicate, or the
+// combination of other guards by And, Or or Not.
+type Guard struct {
+	op       op
+	name     string
+	children []plinko.Guard
+	check    plinko.Predicate
+}
+
+// Check evaluates the guard, returning an error when it does not pass.
+func (g Guard) Check(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) error {
+	if g.check == nil {
+		return nil
+	}
+
+	return g.check(ctx, p, t)
+}
+
+// String renders the guard as an expression, such as "IsCancellable && !IsFlagged".
+func (g Guard) String() string {
+	switch g.op {
+	case not:
+		return "!" + operand(g.children[0], not)
+	case and:
+		return g.join(" && ")
+	case or:
+		return g.join(" || ")
+	}
+
+	return g.name
+}
+
+func (g Guard) join(sep string) string {
+	parts := make([]string, len(g.children))
+	for i, c := range g.children {
+		parts[i] = operand(c, g.op)
+	}
+
+	return strings.Join(parts, sep)
+}
+
+// operand renders the guard as an operand of the parent operator, parenthesizing combinations
+// of a different operator.
+func operand(g plinko.Guard, parent op) string {
+	c, ok := g.(Guard)
+	if !ok || c.op == leaf || c.op == not || c.op == parent {
+		return describe(g)
+	}
+
+	return "(" + c.String() + ")"
+}
+
+// Named gives the predicate a name to render and to report when it fails.
+func Named(name string, predicate plinko.Predicate) Guard {
+	return Guard{op: leaf, name: name, check: func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) error {
+		if predicate == nil {
+			return nil
+		}
+
+		if err := predicate(ctx, p, t); err != nil {
+			return &Error{Guard: name, Err: err}
+		}
+
+		return nil
+	}}
+}
+
+// Func turns the predicate into a guard named after its function.
+func Func(predicate plinko.Predicate) Guard {
+	return Guard{op: leaf, name: nameOf(predicate), check: predicate}
+}
+
+// And passes when every guard passes, evaluating them in order and stopping at the first
+// that fails.
+func And(guards ...plinko.Guard) Guard {
+	return Guard{op: and, children: guards, check: func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) error {
+		for _, g := range guards {
+			if err := evaluate(ctx, g, p, t); err != nil {
+				return explain(g, err)
+			}
+		}
+
+		return nil
+	}}
+}
+
+// Or passes when any of the guards passes, evaluating them in order and stopping at the
+// first that passes.
+func Or(guards ...plinko.Guard) Guard {
+	g := Guard{op: or, children: guards}
+
+	g.check = func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) error {
+		var failures []string
+		for _, c := range guards {
+			err := evaluate(ctx, c, p, t)
+			if err == nil {
+				return nil
+			}
+
+			failures = append(failures, explain(c, err).Error())
+		}
+
+		return &Error{Guard: g.String(), Err: errors.New(strings.Join(failures, "; "))}
+	}
+
+	return g
+}
+
+// Not passes when the guard fails.
+func Not(guard plinko.Guard) Guard {
+	g := Guard{op: not, children: []plinko.Guard{guard}}
+
+	g.check = func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) error {
+		if err := evaluate(ctx, guard, p, t); err != nil {
+			return nil
+		}
+
+		return &Error{Guard: g.String(), Err: fmt.Errorf("guard '%s' passed", describe(guard))}
+	}
+
+	return g
+}
+
+func evaluate(ctx context.Context, g plinko.Guard, p plinko.Payload, t plinko.TransitionInfo) error {
+	if g == nil {
+		return nil
+	}
+
+	return g.Check(ctx, p, t)
+}
+
+func describe(g plinko.Guard) string {
+	if g == nil {
+		return "true"
+	}
+
+	return g.String()
+}
+
+// explain names the guard in its error, unless the guard already explained itself.
+func explain(g plinko.Guard, err error) error {
+	var ge *Error
+	if errors.As(err, &ge) {
+		return err
+	}
+
+	return &Error{Guard: describe(g), Err: err}
+}
+
+func nameOf(predicate plinko.Predicate) string {
+	if predicate == nil {
+		return "true"
+	}
+
+	rf := runtime.FuncForPC(reflect.ValueOf(predicate).Pointer())
+	if rf == nil {
+		return "guard"
+	}
+
+	names := strings.Split(rf.Name(), ".")
+
+	return names[len(names)-1]
+}
@@ -0,0 +1,108 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package guard
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/config"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+const Opened plinko.State = "Opened"
+const Canceled plinko.State = "Canceled"
+
+const Cancel plinko.Trigger = "Cancel"
+
+type order struct {
+	state       plinko.State
+	cancellable bool
+	flagged     bool
+	admin       bool
+}
+
+func (o *order) GetState() plinko.State {
+	return o.state
+}
+
+func check(ok func(*order) bool, reason string) plinko.Predicate {
+	return func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) error {
+		if ok(p.(*order)) {
+			return nil
+		}
+		return errors.New(reason)
+	}
+}
+
+var isCancellable = Named("IsCancellable", check(func(o *order) bool { return o.cancellable }, "order is not cancellable"))
+var isFlagged = Named("IsFlagged", check(func(o *order) bool { return o.flagged }, "order is not flagged"))
+var isAdmin = Named("IsAdmin", check(func(o *order) bool { return o.admin }, "caller is not an admin"))
+
+func IsWeekday(context.Context, plinko.Payload, plinko.TransitionInfo) error {
+	return nil
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "IsCancellable", isCancellable.String())
+	assert.Equal(t, "IsCancellable && !IsFlagged", And(isCancellable, Not(isFlagged)).String())
+	assert.Equal(t, "(IsCancellable && !IsFlagged) || IsAdmin", Or(And(isCancellable, Not(isFlagged)), isAdmin).String())
+	assert.Equal(t, "!(IsCancellable || IsAdmin)", Not(Or(isCancellable, isAdmin)).String())
+	assert.Equal(t, "IsCancellable && IsWeekday", And(isCancellable, Func(IsWeekday)).String())
+	assert.Equal(t, "IsWeekday", Func(IsWeekday).String())
+	assert.Equal(t, "IsCancellable && true", And(isCancellable, nil).String())
+}
+
+func TestEvaluate(t *testing.T) {
+	g := Or(And(isCancellable, Not(isFlagged)), isAdmin)
+
+	assert.Nil(t, g.Check(context.TODO(), &order{cancellable: true}, nil))
+	assert.Nil(t, g.Check(context.TODO(), &order{flagged: true, admin: true}, nil))
+
+	err := And(isCancellable, Not(isFlagged)).Check(context.TODO(), &order{cancellable: true, flagged: true}, nil)
+	var ge *Error
+	assert.True(t, errors.As(err, &ge))
+	assert.Equal(t, "!IsFlagged", ge.Guard)
+
+	err = And(isCancellable, Not(isFlagged)).Check(context.TODO(), &order{}, nil)
+	assert.EqualError(t, err, "guard 'IsCancellable' failed: order is not cancellable")
+
+	err = g.Check(context.TODO(), &order{flagged: true}, nil)
+	assert.True(t, errors.As(err, &ge))
+	assert.Equal(t, "(IsCancellable && !IsFlagged) || IsAdmin", ge.Guard)
+	assert.True(t, strings.Contains(err.Error(), "guard 'IsCancellable' failed: order is not cancellable; guard 'IsAdmin' failed: caller is not an admin"))
+
+	assert.EqualError(t, And(Func(IsWeekday), Not(Func(IsWeekday))).Check(context.TODO(), &order{}, nil), "guard '!IsWeekday' failed: guard 'IsWeekday' passed")
+}
+
+func TestGuardedDefinition(t *testing.T) {
+	p := config.CreatePlinkoDefinition()
+
+	p.Configure(Opened).
+		PermitGuard(And(isCancellable, Not(isFlagged)), Cancel, Canceled)
+
+	p.Configure(Canceled)
+
+	uml, err := p.RenderUml()
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(uml), "Opened --> Canceled : Cancel [IsCancellable && !IsFlagged]"))
+
+	sm := p.Compile().StateMachine
+
+	_, err = sm.Fire(context.TODO(), &order{state: Opened, cancellable: true, flagged: true}, Cancel)
+
+	var pte *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &pte))
+
+	var ge *Error
+	assert.True(t, errors.As(err, &ge))
+	assert.Equal(t, "!IsFlagged", ge.Guard)
+}
@@ -0,0 +1,139 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shipt/plinko"
+)
+
+type entry struct {
+	result  plinko.IdempotentResult
+	expires time.Time
+
+	// pending is closed once the call that reserved the key records its outcome
+	pending chan struct{}
+}
+
+// Store is an in-memory plinko.IdempotencyStore that forgets outcomes once their time to live
+// has passed.  A reservation that is never resolved is forgotten after the same time to live.
+// Expired outcomes are never returned, and are removed by a sweep that runs at most once per
+// time to live.
+type Store struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]entry
+	now     func() time.Time
+	sweepAt time.Time
+}
+
+// NewStore creates a store remembering each outcome for ttl.
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:     ttl,
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+// Reserve returns the outcome recorded for the key, if it has not expired, or claims the key
+// for the caller.  A key claimed by another call is waited on until its outcome is recorded.
+func (s *Store) Reserve(ctx context.Context, key string) (plinko.IdempotentResult, bool, error) {
+	for {
+		s.mu.Lock()
+		now := s.now()
+
+		e, ok := s.entries[key]
+		if ok && !now.Before(e.expires) {
+			s.forget(key, e)
+			ok = false
+		}
+
+		if !ok {
+			s.entries[key] = entry{expires: now.Add(s.ttl), pending: make(chan struct{})}
+			s.mu.Unlock()
+			return plinko.IdempotentResult{}, false, nil
+		}
+
+		if e.pending == nil {
+			s.mu.Unlock()
+			return e.result, true, nil
+		}
+
+		pending := e.pending
+		s.mu.Unlock()
+
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return plinko.IdempotentResult{}, false, ctx.Err()
+		}
+	}
+}
+
+// Put records the outcome for the key, releasing the calls waiting on it.  Once a time to live
+// has passed since the last sweep, it also removes any outcomes that have expired.
+func (s *Store) Put(_ context.Context, key string, result plinko.IdempotentResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if e, ok := s.entries[key]; ok {
+		s.forget(key, e)
+	}
+
+	if !now.Before(s.sweepAt) {
+		s.sweep(now)
+	}
+
+	s.entries[key] = entry{result: result, expires: now.Add(s.ttl)}
+
+	return nil
+}
+
+// sweep removes the outcomes and reservations that have expired.
+func (s *Store) sweep(now time.Time) {
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			s.forget(k, e)
+		}
+	}
+
+	s.sweepAt = now.Add(s.ttl)
+}
+
+// Release gives up the reservation of the key without recording an outcome, so the next call
+// with the key claims it again.  An outcome already recorded for the key is kept.
+func (s *Store) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.pending != nil {
+		s.forget(key, e)
+	}
+
+	return nil
+}
+
+// forget removes the entry, releasing any calls waiting on its reservation.
+func (s *Store) forget(key string, e entry) {
+	if e.pending != nil {
+		close(e.pending)
+	}
+	delete(s.entries, key)
+}
+
+// Len returns the number of outcomes held, including expired outcomes not yet removed.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
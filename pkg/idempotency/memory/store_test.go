@@ -0,0 +1,132 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreExpiresOutcomes(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	s := NewStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	_, ok, err := s.Reserve(context.TODO(), "capture-1")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	outcome := plinko.IdempotentResult{Err: errors.New("declined")}
+	assert.Nil(t, s.Put(context.TODO(), "capture-1", outcome))
+
+	now = now.Add(59 * time.Second)
+	result, ok, err := s.Reserve(context.TODO(), "capture-1")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, outcome, result)
+
+	assert.Nil(t, s.Put(context.TODO(), "capture-2", plinko.IdempotentResult{}))
+	assert.Equal(t, 2, s.Len())
+
+	now = now.Add(time.Second)
+	_, ok, _ = s.Reserve(context.TODO(), "capture-1")
+	assert.False(t, ok)
+	assert.Equal(t, 2, s.Len())
+
+	now = now.Add(time.Minute)
+	assert.Nil(t, s.Put(context.TODO(), "capture-3", plinko.IdempotentResult{}))
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestStoreSweepsOncePerTTL(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	s := NewStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	assert.Nil(t, s.Put(context.TODO(), "capture-1", plinko.IdempotentResult{}))
+
+	now = now.Add(50 * time.Second)
+	assert.Nil(t, s.Put(context.TODO(), "capture-2", plinko.IdempotentResult{}))
+
+	now = now.Add(10 * time.Second)
+	assert.Nil(t, s.Put(context.TODO(), "capture-3", plinko.IdempotentResult{}))
+	assert.Equal(t, 2, s.Len())
+
+	// capture-2 has expired, but no sweep is due until a minute after the last one
+	now = now.Add(50 * time.Second)
+	assert.Nil(t, s.Put(context.TODO(), "capture-4", plinko.IdempotentResult{}))
+	assert.Equal(t, 3, s.Len())
+
+	result, ok, err := s.Reserve(context.TODO(), "capture-3")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, plinko.IdempotentResult{}, result)
+
+	now = now.Add(10 * time.Second)
+	assert.Nil(t, s.Put(context.TODO(), "capture-5", plinko.IdempotentResult{}))
+	assert.Equal(t, 2, s.Len())
+}
+
+func TestStoreReservesKeys(t *testing.T) {
+	s := NewStore(time.Minute)
+
+	_, ok, err := s.Reserve(context.TODO(), "capture-1")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Millisecond)
+	defer cancel()
+
+	_, _, err = s.Reserve(ctx, "capture-1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	outcome := plinko.IdempotentResult{Err: errors.New("declined")}
+	reserved := make(chan plinko.IdempotentResult)
+	go func() {
+		result, ok, err := s.Reserve(context.TODO(), "capture-1")
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		reserved <- result
+	}()
+
+	assert.Nil(t, s.Put(context.TODO(), "capture-1", outcome))
+	assert.Equal(t, outcome, <-reserved)
+}
+
+func TestStoreReleasesKeys(t *testing.T) {
+	s := NewStore(time.Minute)
+
+	_, ok, err := s.Reserve(context.TODO(), "capture-1")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	released := make(chan bool)
+	go func() {
+		_, ok, err := s.Reserve(context.TODO(), "capture-1")
+		assert.Nil(t, err)
+		released <- ok
+	}()
+
+	// the waiting call claims the released key rather than receiving an outcome
+	assert.Nil(t, s.Release(context.TODO(), "capture-1"))
+	assert.False(t, <-released)
+
+	outcome := plinko.IdempotentResult{Err: errors.New("declined")}
+	assert.Nil(t, s.Put(context.TODO(), "capture-1", outcome))
+
+	// releasing a recorded outcome keeps it
+	assert.Nil(t, s.Release(context.TODO(), "capture-1"))
+	result, ok, err := s.Reserve(context.TODO(), "capture-1")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, outcome, result)
+}
@@ -0,0 +1,87 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package journal provides a JSON-lines plinko.Journal and the replay of recorded journals
+// against a definition.
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/shipt/plinko"
+)
+
+// JSONLines is a plinko.Journal writing every entry as a single line of JSON.
+type JSONLines struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLines creates a journal writing to w.
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{
+		w:   w,
+		enc: json.NewEncoder(w),
+	}
+}
+
+// OpenFile opens, or creates, the file at path and appends journal entries to it.  The
+// journal must be closed to release the file.
+func OpenFile(path string) (*JSONLines, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewJSONLines(f), nil
+}
+
+// Record writes the entry as a line of JSON.
+func (j *JSONLines) Record(_ context.Context, _ plinko.Payload, entry plinko.JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.enc.Encode(entry)
+}
+
+// Close closes the underlying writer when it is an io.Closer.
+func (j *JSONLines) Close() error {
+	if c, ok := j.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// ReadEntries reads the entries written by a JSONLines journal.
+func ReadEntries(r io.Reader) ([]plinko.JournalEntry, error) {
+	var entries []plinko.JournalEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry plinko.JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,58 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package journal
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLinesRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	j := NewJSONLines(&b)
+
+	timestamp := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	entries := []plinko.JournalEntry{
+		{ID: "order-1", Source: Created, Destination: Opened, Trigger: Open, Timestamp: timestamp, Elapsed: time.Millisecond, Operations: []string{"OnOpen"}},
+		{ID: "order-1", Source: Opened, Destination: Claimed, Trigger: Claim, Timestamp: timestamp.Add(time.Minute)},
+	}
+
+	for _, entry := range entries {
+		assert.Nil(t, j.Record(context.TODO(), nil, entry))
+	}
+
+	assert.Equal(t, 2, strings.Count(b.String(), "\n"))
+
+	read, err := ReadEntries(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, entries, read)
+}
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	for i := 0; i < 2; i++ {
+		j, err := OpenFile(path)
+		assert.Nil(t, err)
+		assert.Nil(t, j.Record(context.TODO(), nil, plinko.JournalEntry{Source: Created, Destination: Opened, Trigger: Open}))
+		assert.Nil(t, j.Close())
+	}
+
+	j, err := OpenFile(path)
+	assert.Nil(t, err)
+	defer j.Close()
+
+	_, err = ReadEntries(strings.NewReader("{not json}\n"))
+	assert.NotNil(t, err)
+}
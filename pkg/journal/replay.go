@@ -0,0 +1,142 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package journal
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shipt/plinko"
+)
+
+// Divergence describes a journal entry that does not agree with the definition.
+type Divergence struct {
+	Index  int
+	Entry  plinko.JournalEntry
+	Reason string
+}
+
+// ReplayResult is the outcome of replaying a journal.
+type ReplayResult struct {
+	// State is the state the journal leaves the entity in.
+	State       plinko.State
+	Divergences []Divergence
+}
+
+type edge struct {
+	destination plinko.State
+	config      plinko.TriggerConfig
+}
+
+// model captures the states and transitions of a definition through its Graph.
+type model struct {
+	parents map[plinko.State]plinko.State
+	edges   map[plinko.State]map[plinko.Trigger]edge
+}
+
+func (m *model) Render(g plinko.Graph) error {
+	g.Nodes(func(state plinko.State, config plinko.StateConfig) {
+		m.parents[state] = config.Parent
+	})
+
+	g.Edges(func(state, destination plinko.State, trigger plinko.Trigger, config plinko.TriggerConfig) {
+		if m.edges[state] == nil {
+			m.edges[state] = map[plinko.Trigger]edge{}
+		}
+		m.edges[state][trigger] = edge{destination: destination, config: config}
+	})
+
+	return nil
+}
+
+// isDescendant reports whether state is nested, at any depth, within ancestor.
+func (m *model) isDescendant(state, ancestor plinko.State) bool {
+	seen := map[plinko.State]bool{}
+
+	for parent := m.parents[state]; parent != "" && !seen[parent]; parent = m.parents[parent] {
+		if parent == ancestor {
+			return true
+		}
+		seen[parent] = true
+	}
+
+	return false
+}
+
+// resolve finds the transition for the trigger from the state or, failing that, the nearest
+// of its parent states.
+func (m *model) resolve(state plinko.State, trigger plinko.Trigger) (edge, bool) {
+	seen := map[plinko.State]bool{}
+
+	for s := state; s != "" && !seen[s]; s = m.parents[s] {
+		if e, ok := m.edges[s][trigger]; ok {
+			return e, true
+		}
+		seen[s] = true
+	}
+
+	return edge{}, false
+}
+
+// Replay re-derives the state of an entity from its journal, validating every recorded
+// transition against the definition.  Guards are not evaluated, as the payloads they were
+// evaluated against are not recorded.  Replay continues past a divergence from the
+// destination that was recorded, so every divergence in the journal is reported.
+func Replay(definition plinko.PlinkoDefinition, entries []plinko.JournalEntry) (ReplayResult, error) {
+	for _, msg := range definition.Compile().Messages {
+		if msg.CompileMessage == plinko.CompileError {
+			return ReplayResult{}, errors.New("critical errors exist in definition")
+		}
+	}
+
+	m := &model{
+		parents: map[plinko.State]plinko.State{},
+		edges:   map[plinko.State]map[plinko.Trigger]edge{},
+	}
+	if err := definition.Render(m); err != nil {
+		return ReplayResult{}, err
+	}
+
+	var result ReplayResult
+	for i, entry := range entries {
+		if reason := m.validate(result.State, entry); reason != "" {
+			result.Divergences = append(result.Divergences, Divergence{Index: i, Entry: entry, Reason: reason})
+		}
+
+		// a transition within a region of a parallel state leaves the entity in the parallel state
+		if result.State == "" || !m.isDescendant(entry.Source, result.State) {
+			result.State = entry.Destination
+		}
+	}
+
+	return result, nil
+}
+
+func (m *model) validate(current plinko.State, entry plinko.JournalEntry) string {
+	if _, ok := m.parents[entry.Source]; !ok {
+		return fmt.Sprintf("State '%s' is not defined", entry.Source)
+	}
+
+	if current != "" && entry.Source != current && !m.isDescendant(entry.Source, current) {
+		return fmt.Sprintf("Transition leaves state '%s', but the entity is in state '%s'", entry.Source, current)
+	}
+
+	e, ok := m.resolve(entry.Source, entry.Trigger)
+	if !ok {
+		return fmt.Sprintf("Trigger '%s' is not permitted in state '%s'", entry.Trigger, entry.Source)
+	}
+
+	if entry.Destination == e.destination {
+		return ""
+	}
+
+	if e.config.History != plinko.NoHistory && m.isDescendant(entry.Destination, e.destination) {
+		return ""
+	}
+
+	return fmt.Sprintf("Trigger '%s' transitions state '%s' to '%s', not '%s'", entry.Trigger, entry.Source, e.destination, entry.Destination)
+}
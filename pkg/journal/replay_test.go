@@ -0,0 +1,114 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package journal
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/config"
+	"github.com/shipt/plinko/pkg/config/definition"
+	"github.com/stretchr/testify/assert"
+)
+
+const Created plinko.State = "Created"
+const Opened plinko.State = "Opened"
+const Claimed plinko.State = "Claimed"
+const Canceled plinko.State = "Canceled"
+
+const Open plinko.Trigger = "Open"
+const Claim plinko.Trigger = "Claim"
+const Cancel plinko.Trigger = "Cancel"
+
+type order struct {
+	state plinko.State
+}
+
+func (o *order) GetState() plinko.State {
+	return o.state
+}
+
+func setState(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+	p.(*order).state = t.GetDestination()
+	return p, nil
+}
+
+func createDefinition(opts ...plinko.DefinitionOption) plinko.PlinkoDefinition {
+	p := config.CreatePlinkoDefinition(opts...)
+
+	p.Configure(Created).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Opened).
+		OnEntry(setState).
+		Permit(Claim, Claimed).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Claimed).
+		OnEntry(setState)
+
+	p.Configure(Canceled).
+		OnEntry(setState)
+
+	return p
+}
+
+func TestReplayJournal(t *testing.T) {
+	var b bytes.Buffer
+	sm := createDefinition(definition.WithJournal(NewJSONLines(&b))).Compile().StateMachine
+
+	payload := &order{state: Created}
+	for _, trigger := range []plinko.Trigger{Open, Claim} {
+		_, err := sm.Fire(context.TODO(), payload, trigger)
+		assert.Nil(t, err)
+	}
+
+	entries, err := ReadEntries(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	result, err := Replay(createDefinition(), entries)
+	assert.Nil(t, err)
+	assert.Equal(t, Claimed, result.State)
+	assert.Equal(t, 0, len(result.Divergences))
+}
+
+func TestReplayDivergences(t *testing.T) {
+	entries := []plinko.JournalEntry{
+		{Source: Created, Destination: Opened, Trigger: Open},
+		{Source: Created, Destination: Canceled, Trigger: Cancel},
+		{Source: Canceled, Destination: Claimed, Trigger: Claim},
+		{Source: Claimed, Destination: Opened, Trigger: Open},
+	}
+
+	result, err := Replay(createDefinition(), entries)
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, result.State)
+
+	assert.Equal(t, []Divergence{
+		{Index: 1, Entry: entries[1], Reason: "Transition leaves state 'Created', but the entity is in state 'Opened'"},
+		{Index: 2, Entry: entries[2], Reason: "Trigger 'Claim' is not permitted in state 'Canceled'"},
+		{Index: 3, Entry: entries[3], Reason: "Trigger 'Open' is not permitted in state 'Claimed'"},
+	}, result.Divergences)
+
+	result, err = Replay(createDefinition(), []plinko.JournalEntry{
+		{Source: Created, Destination: Claimed, Trigger: Open},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "Trigger 'Open' transitions state 'Created' to 'Opened', not 'Claimed'", result.Divergences[0].Reason)
+}
+
+func TestReplayInvalidDefinition(t *testing.T) {
+	p := config.CreatePlinkoDefinition()
+	p.Configure(Created).Permit(Open, Opened)
+
+	_, err := Replay(p, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,113 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package locker provides an in-process plinko.Locker.
+package locker
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStripes is the number of stripes used when NewStriped is given a non-positive count.
+const DefaultStripes = 256
+
+// Stats reports the contention seen by a Striped locker.
+type Stats struct {
+	// Acquired counts the locks that were taken.
+	Acquired uint64
+	// Contended counts the locks that were not free when requested and had to be waited on.
+	Contended uint64
+	// Canceled counts the waits abandoned because their context was done.
+	Canceled uint64
+	// Waited is the total time spent waiting on contended locks.
+	Waited time.Duration
+}
+
+// Striped is a plinko.Locker serializing entities within a single process.  Entity ids are
+// hashed onto a fixed set of stripes, so memory does not grow with the number of entities, at
+// the cost of unrelated entities occasionally sharing a stripe.
+type Striped struct {
+	stripes []chan struct{}
+
+	acquired  uint64
+	contended uint64
+	canceled  uint64
+	waited    int64
+}
+
+// NewStriped creates a locker with the given number of stripes.
+func NewStriped(stripes int) *Striped {
+	if stripes <= 0 {
+		stripes = DefaultStripes
+	}
+
+	s := &Striped{
+		stripes: make([]chan struct{}, stripes),
+	}
+
+	for i := range s.stripes {
+		s.stripes[i] = make(chan struct{}, 1)
+	}
+
+	return s
+}
+
+func (s *Striped) index(id string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+
+	return int(h.Sum32() % uint32(len(s.stripes)))
+}
+
+// LockKey returns the stripe the id is locked under, so a Fire nested within an operation of
+// another entity sharing the stripe runs under the lock its caller holds rather than waiting
+// on it forever.
+func (s *Striped) LockKey(id string) string {
+	return strconv.Itoa(s.index(id))
+}
+
+// Lock waits for the lock of the id until the context is done.
+func (s *Striped) Lock(ctx context.Context, id string) (func(), error) {
+	stripe := s.stripes[s.index(id)]
+	unlock := func() { <-stripe }
+
+	select {
+	case stripe <- struct{}{}:
+		atomic.AddUint64(&s.acquired, 1)
+		return unlock, nil
+	default:
+	}
+
+	atomic.AddUint64(&s.contended, 1)
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(&s.waited, int64(time.Since(start)))
+	}()
+
+	select {
+	case stripe <- struct{}{}:
+		atomic.AddUint64(&s.acquired, 1)
+		return unlock, nil
+	case <-ctx.Done():
+		atomic.AddUint64(&s.canceled, 1)
+		return nil, ctx.Err()
+	}
+}
+
+// Stats returns the contention seen since the locker was created.
+func (s *Striped) Stats() Stats {
+	return Stats{
+		Acquired:  atomic.LoadUint64(&s.acquired),
+		Contended: atomic.LoadUint64(&s.contended),
+		Canceled:  atomic.LoadUint64(&s.canceled),
+		Waited:    time.Duration(atomic.LoadInt64(&s.waited)),
+	}
+}
@@ -0,0 +1,68 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package locker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripedLock(t *testing.T) {
+	s := NewStriped(1)
+
+	unlock, err := s.Lock(context.TODO(), "order-1")
+	assert.Nil(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock, err := s.Lock(context.TODO(), "order-1")
+		assert.Nil(t, err)
+		close(acquired)
+		unlock()
+	}()
+
+	select {
+	case <-acquired:
+		assert.Fail(t, "lock acquired while held")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	unlock()
+	<-acquired
+
+	stats := s.Stats()
+	assert.Equal(t, uint64(2), stats.Acquired)
+	assert.Equal(t, uint64(1), stats.Contended)
+	assert.True(t, stats.Waited > 0)
+}
+
+func TestStripedLockHonorsContext(t *testing.T) {
+	s := NewStriped(0)
+	assert.Equal(t, DefaultStripes, len(s.stripes))
+
+	unlock, err := s.Lock(context.TODO(), "order-1")
+	assert.Nil(t, err)
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Millisecond)
+	defer cancel()
+
+	_, err = s.Lock(ctx, "order-1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, uint64(1), s.Stats().Canceled)
+}
+
+func TestStripedLockKey(t *testing.T) {
+	s := NewStriped(1)
+	assert.Equal(t, s.LockKey("order-1"), s.LockKey("order-2"))
+
+	s = NewStriped(DefaultStripes)
+	assert.Equal(t, s.LockKey("order-1"), s.LockKey("order-1"))
+}
@@ -0,0 +1,190 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+type message struct {
+	ctx     context.Context
+	trigger plinko.Trigger
+	args    []interface{}
+	future  *Future
+}
+
+// entity is the in-memory state of an active entity along with its mailbox.
+type entity struct {
+	r  *Runner
+	id string
+
+	mu      sync.Mutex
+	mailbox []message
+	signal  chan struct{}
+
+	payload plinko.Payload
+	version int64
+	loaded  bool
+	dirty   bool
+}
+
+func newEntity(r *Runner, id string) *entity {
+	return &entity{
+		r:      r,
+		id:     id,
+		signal: make(chan struct{}, 1),
+	}
+}
+
+func (e *entity) enqueue(m message) {
+	e.mu.Lock()
+	e.mailbox = append(e.mailbox, m)
+	e.mu.Unlock()
+
+	select {
+	case e.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (e *entity) dequeue() (message, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.mailbox) == 0 {
+		return message{}, false
+	}
+
+	m := e.mailbox[0]
+	e.mailbox[0] = message{}
+	e.mailbox = e.mailbox[1:]
+
+	return m, true
+}
+
+func (e *entity) pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.mailbox)
+}
+
+func (e *entity) run() {
+	defer e.r.wg.Done()
+
+	idle := time.NewTimer(e.r.idleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-e.signal:
+			e.drain()
+
+		case <-idle.C:
+			if e.passivate(false) {
+				return
+			}
+
+		case <-e.r.closing:
+			e.drain()
+			e.passivate(true)
+			return
+		}
+
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+		idle.Reset(e.r.idleTimeout)
+	}
+}
+
+func (e *entity) drain() {
+	for {
+		m, ok := e.dequeue()
+		if !ok {
+			return
+		}
+
+		m.future.resolve(e.process(m))
+	}
+}
+
+func (e *entity) process(m message) (plinko.Payload, error) {
+	if err := m.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if !e.loaded {
+		payload, version, err := e.r.store.Load(m.ctx, e.id)
+		if err != nil {
+			return nil, err
+		}
+
+		e.payload, e.version, e.loaded = payload, version, true
+	}
+
+	// the trigger is fired on a copy, so a failed fire leaves the held payload as it was
+	payload, err := e.r.sm.Fire(plinko.WithTriggerArgs(m.ctx, m.args...), snapshot(e.payload), m.trigger)
+
+	// a payload routed to the failure state has changed even though the fire failed
+	var fse *plinkoerror.PlinkoFailureStateError
+	if err != nil && !errors.As(err, &fse) {
+		return payload, err
+	}
+
+	if payload != nil {
+		e.payload, e.dirty = payload, true
+	}
+
+	// the caller receives a copy, as the held payload is changed by the triggers that follow
+	return snapshot(payload), err
+}
+
+// snapshot copies a payload that implements plinko.Cloneable.  Other payloads are shared with
+// the caller and are only safe to read once the entity has been passivated.
+func snapshot(payload plinko.Payload) plinko.Payload {
+	if cloneable, ok := payload.(plinko.Cloneable); ok {
+		return cloneable.Clone()
+	}
+
+	return payload
+}
+
+// passivate saves the entity when it has changed and releases it.  Unless forced, an entity
+// that fails to save, or received a trigger while saving, stays active.  An entity whose save
+// conflicts with a concurrent update of the store is dropped, so it is loaded again on its
+// next trigger.
+func (e *entity) passivate(force bool) bool {
+	if e.dirty {
+		version, err := e.r.store.Save(context.Background(), e.id, e.payload, e.version)
+
+		var ce *plinkoerror.PlinkoConflictError
+		switch {
+		case errors.As(err, &ce):
+			e.r.errorHandler(e.id, err)
+			e.payload, e.loaded, e.dirty = nil, false, false
+		case err != nil:
+			e.r.errorHandler(e.id, err)
+			if !force {
+				return false
+			}
+		default:
+			e.version, e.dirty = version, false
+		}
+	}
+
+	return e.r.release(e, force)
+}
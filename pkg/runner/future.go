@@ -0,0 +1,46 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runner
+
+import (
+	"context"
+
+	"github.com/shipt/plinko"
+)
+
+// Future is the pending outcome of a trigger sent to a Runner.
+type Future struct {
+	done    chan struct{}
+	payload plinko.Payload
+	err     error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) resolve(payload plinko.Payload, err error) {
+	f.payload = payload
+	f.err = err
+	close(f.done)
+}
+
+// Done is closed once the trigger has been processed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits for the trigger to be processed and returns the outcome of firing it.  It
+// returns the context error when the context is done first; the trigger is still processed.
+func (f *Future) Result(ctx context.Context) (plinko.Payload, error) {
+	select {
+	case <-f.done:
+		return f.payload, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,161 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package runner processes the triggers of many entities, each strictly in order, without
+// external locks.
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shipt/plinko"
+)
+
+// DefaultIdleTimeout is how long an entity stays active without receiving triggers before it
+// is passivated, when no timeout is configured.
+const DefaultIdleTimeout = time.Minute
+
+// ErrClosed is returned for triggers sent to a runner that has been closed.
+var ErrClosed = errors.New("runner is closed")
+
+// Runner owns a goroutine for every active entity.  The triggers sent to an entity are queued
+// in its mailbox and fired one at a time, in the order they were sent, against the payload the
+// runner holds in memory.  An entity is loaded from the store when it receives its first
+// trigger, and passivated, saved back to the store and released, once it has been idle for the
+// idle timeout.
+//
+// Payloads implementing plinko.Cloneable are fired on a copy, so a trigger that fails without
+// being routed to the failure state leaves the held payload untouched, and every Future
+// resolves with a copy the entity no longer changes.
+type Runner struct {
+	sm           plinko.StateMachine
+	store        plinko.Store
+	idleTimeout  time.Duration
+	errorHandler func(id string, err error)
+
+	mu       sync.Mutex
+	entities map[string]*entity
+	closed   bool
+	closing  chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Option configures a Runner.
+type Option func(r *Runner)
+
+// WithIdleTimeout sets how long an entity stays active without receiving triggers.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(r *Runner) {
+		r.idleTimeout = timeout
+	}
+}
+
+// WithErrorHandler sets the function told about errors saving passivated entities, which no
+// caller is waiting on.  An entity that fails to save stays active and is saved again when it
+// next becomes idle, or when the runner closes, unless the save conflicted with a concurrent
+// update of the store: its changes are then discarded and it is loaded again on its next
+// trigger.
+func WithErrorHandler(handler func(id string, err error)) Option {
+	return func(r *Runner) {
+		r.errorHandler = handler
+	}
+}
+
+// New creates a runner firing triggers with the state machine on payloads held in the store.
+func New(sm plinko.StateMachine, store plinko.Store, opts ...Option) *Runner {
+	r := &Runner{
+		sm:           sm,
+		store:        store,
+		idleTimeout:  DefaultIdleTimeout,
+		errorHandler: func(string, error) {},
+		entities:     make(map[string]*entity),
+		closing:      make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Send queues the trigger for the entity and returns the future of its outcome.  The arguments
+// are available to the operations of the transition through plinko.TriggerArgs.  The context
+// is passed to Fire; a trigger whose context is done before it is processed fails with the
+// context error.
+func (r *Runner) Send(ctx context.Context, id string, trigger plinko.Trigger, args ...interface{}) *Future {
+	f := newFuture()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		f.resolve(nil, ErrClosed)
+		return f
+	}
+
+	e, ok := r.entities[id]
+	if !ok {
+		e = newEntity(r, id)
+		r.entities[id] = e
+		r.wg.Add(1)
+		go e.run()
+	}
+
+	e.enqueue(message{ctx: ctx, trigger: trigger, args: args, future: f})
+
+	return f
+}
+
+// Active returns the number of entities currently held in memory.
+func (r *Runner) Active() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.entities)
+}
+
+// Close stops accepting triggers, waits for the queued triggers to be processed and passivates
+// every entity.  It returns the context error if the context is done first.
+func (r *Runner) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.closing)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release removes an idle entity, unless a trigger arrived for it in the meantime.
+func (r *Runner) release(e *entity, force bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !force && e.pending() > 0 {
+		return false
+	}
+
+	delete(r.entities, e.id)
+
+	return true
+}
@@ -0,0 +1,282 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/config"
+	"github.com/shipt/plinko/pkg/config/definition"
+	"github.com/shipt/plinko/pkg/store/memory"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+const Created plinko.State = "Created"
+const Opened plinko.State = "Opened"
+const Canceled plinko.State = "Canceled"
+const Failed plinko.State = "Failed"
+
+const Open plinko.Trigger = "Open"
+const AddItem plinko.Trigger = "AddItem"
+const Cancel plinko.Trigger = "Cancel"
+
+type order struct {
+	state plinko.State
+	items []string
+}
+
+func (o *order) GetState() plinko.State {
+	return o.state
+}
+
+func (o *order) Clone() plinko.Payload {
+	c := *o
+	c.items = append([]string(nil), o.items...)
+	return &c
+}
+
+func createStateMachine() plinko.StateMachine {
+	p := config.CreatePlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			o := p.(*order)
+			o.state = t.GetDestination()
+
+			if args := plinko.TriggerArgs(ctx); len(args) > 0 {
+				o.items = append(o.items, args[0].(string))
+			}
+
+			return o, nil
+		}).
+		PermitReentry(AddItem)
+
+	return p.Compile().StateMachine
+}
+
+func createStore(t *testing.T, ids ...string) *memory.Store {
+	store := memory.NewStore()
+	for _, id := range ids {
+		_, err := store.Save(context.TODO(), id, &order{state: Created}, 0)
+		assert.Nil(t, err)
+	}
+
+	return store
+}
+
+func TestSendProcessesInOrder(t *testing.T) {
+	store := createStore(t, "order-1", "order-2")
+	r := New(createStateMachine(), store)
+
+	items := []string{"apples", "bread", "cheese", "dates", "eggs"}
+
+	var futures []*Future
+	futures = append(futures, r.Send(context.TODO(), "order-1", Open))
+	r.Send(context.TODO(), "order-2", Open)
+	for _, item := range items {
+		futures = append(futures, r.Send(context.TODO(), "order-1", AddItem, item))
+	}
+
+	for _, f := range futures {
+		_, err := f.Result(context.TODO())
+		assert.Nil(t, err)
+	}
+
+	payload, err := futures[len(futures)-1].Result(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, items, payload.(*order).items)
+	assert.Equal(t, 2, r.Active())
+
+	_, err = r.Send(context.TODO(), "order-1", Open).Result(context.TODO())
+	var pte *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &pte))
+
+	_, err = r.Send(context.TODO(), "order-3", Open).Result(context.TODO())
+	var nf *plinkoerror.PlinkoNotFoundError
+	assert.True(t, errors.As(err, &nf))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err = r.Send(ctx, "order-1", AddItem, "figs").Result(context.TODO())
+	assert.Equal(t, context.Canceled, err)
+
+	assert.Nil(t, r.Close(context.TODO()))
+	assert.Equal(t, 0, r.Active())
+
+	stored, version, err := store.Load(context.TODO(), "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, items, stored.(*order).items)
+
+	_, err = r.Send(context.TODO(), "order-1", AddItem, "figs").Result(context.TODO())
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestIdleEntitiesArePassivated(t *testing.T) {
+	store := createStore(t, "order-1")
+	r := New(createStateMachine(), store, WithIdleTimeout(5*time.Millisecond))
+
+	_, err := r.Send(context.TODO(), "order-1", Open).Result(context.TODO())
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool { return r.Active() == 0 }, time.Second, time.Millisecond)
+
+	stored, version, err := store.Load(context.TODO(), "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, Opened, stored.GetState())
+
+	// a passivated entity is loaded again on its next trigger
+	_, err = r.Send(context.TODO(), "order-1", AddItem, "apples").Result(context.TODO())
+	assert.Nil(t, err)
+
+	assert.Nil(t, r.Close(context.TODO()))
+
+	_, version, _ = store.Load(context.TODO(), "order-1")
+	assert.Equal(t, int64(3), version)
+}
+
+func TestPassivationErrors(t *testing.T) {
+	store := createStore(t, "order-1")
+
+	var failed []string
+	r := New(createStateMachine(), store, WithErrorHandler(func(id string, err error) {
+		failed = append(failed, id)
+	}))
+
+	_, err := r.Send(context.TODO(), "order-1", Open).Result(context.TODO())
+	assert.Nil(t, err)
+
+	// a concurrent update of the stored entity makes the passivating save conflict
+	_, err = store.Save(context.TODO(), "order-1", &order{state: Created}, 1)
+	assert.Nil(t, err)
+
+	assert.Nil(t, r.Close(context.TODO()))
+	assert.Equal(t, []string{"order-1"}, failed)
+}
+
+func TestPassivationConflictReloads(t *testing.T) {
+	store := createStore(t, "order-1")
+
+	var failed []string
+	r := New(createStateMachine(), store, WithIdleTimeout(5*time.Millisecond), WithErrorHandler(func(id string, err error) {
+		var ce *plinkoerror.PlinkoConflictError
+		assert.True(t, errors.As(err, &ce))
+		failed = append(failed, id)
+	}))
+
+	_, err := r.Send(context.TODO(), "order-1", Open).Result(context.TODO())
+	assert.Nil(t, err)
+
+	_, err = store.Save(context.TODO(), "order-1", &order{state: Created}, 1)
+	assert.Nil(t, err)
+
+	// the conflicting entity is released rather than retried
+	assert.Eventually(t, func() bool { return r.Active() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"order-1"}, failed)
+
+	payload, err := r.Send(context.TODO(), "order-1", Open).Result(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, payload.GetState())
+
+	assert.Nil(t, r.Close(context.TODO()))
+
+	stored, version, _ := store.Load(context.TODO(), "order-1")
+	assert.Equal(t, int64(3), version)
+	assert.Equal(t, Opened, stored.GetState())
+}
+
+func TestFailedFireKeepsPayload(t *testing.T) {
+	setState := func(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+		p.(*order).state = t.GetDestination()
+		return p, nil
+	}
+
+	p := config.CreatePlinkoDefinition(definition.WithFailureState(Failed))
+
+	p.Configure(Created).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, plinkoerror.Fatal(errors.New("refund rejected"))
+		})
+
+	p.Configure(Failed).
+		OnEntry(setState)
+
+	store := createStore(t, "order-1")
+	r := New(p.Compile().StateMachine, store)
+
+	payload, err := r.Send(context.TODO(), "order-1", Cancel).Result(context.TODO())
+	assert.True(t, plinkoerror.IsFatal(err))
+	assert.Equal(t, Failed, payload.GetState())
+
+	assert.Nil(t, r.Close(context.TODO()))
+
+	stored, _, _ := store.Load(context.TODO(), "order-1")
+	assert.Equal(t, Failed, stored.GetState())
+}
+
+func TestFailedFireDiscardsChanges(t *testing.T) {
+	p := config.CreatePlinkoDefinition()
+
+	p.Configure(Created).
+		Permit(Open, Opened)
+
+	p.Configure(Opened).
+		OnEntry(func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			o := p.(*order)
+			o.state = t.GetDestination()
+
+			if args := plinko.TriggerArgs(ctx); len(args) > 0 {
+				o.items = append(o.items, args[0].(string))
+				if args[0] == "spoiled" {
+					return o, errors.New("item rejected")
+				}
+			}
+
+			return o, nil
+		}).
+		PermitReentry(AddItem)
+
+	store := createStore(t, "order-1")
+	r := New(p.Compile().StateMachine, store)
+
+	opened := r.Send(context.TODO(), "order-1", Open)
+	apples := r.Send(context.TODO(), "order-1", AddItem, "apples")
+	spoiled := r.Send(context.TODO(), "order-1", AddItem, "spoiled")
+	bread := r.Send(context.TODO(), "order-1", AddItem, "bread")
+
+	_, err := spoiled.Result(context.TODO())
+	assert.EqualError(t, err, "item rejected")
+
+	// every future holds the payload as it was after its own trigger
+	payload, err := apples.Result(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"apples"}, payload.(*order).items)
+
+	payload, err = bread.Result(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"apples", "bread"}, payload.(*order).items)
+
+	payload, _ = opened.Result(context.TODO())
+	assert.Empty(t, payload.(*order).items)
+
+	assert.Nil(t, r.Close(context.TODO()))
+
+	stored, _, _ := store.Load(context.TODO(), "order-1")
+	assert.Equal(t, []string{"apples", "bread"}, stored.(*order).items)
+}
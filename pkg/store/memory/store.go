@@ -0,0 +1,75 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+type entry struct {
+	payload plinko.Payload
+	version int64
+}
+
+// ErrNotCloneable is returned when saving a payload that does not implement plinko.Cloneable.
+var ErrNotCloneable = errors.New("memory store requires payloads implementing plinko.Cloneable")
+
+// Store is an in-memory reference implementation of plinko.Store, suited to tests and to
+// single-process applications.  Payloads must implement plinko.Cloneable: the store keeps a
+// copy of every payload saved and hands out a copy on every load, so a fire that changes a
+// loaded payload leaves the stored one untouched until it is saved.
+type Store struct {
+	mu      sync.RWMutex
+	entries map[string]entry
+}
+
+// NewStore creates an empty in-memory store.
+func NewStore() *Store {
+	return &Store{
+		entries: make(map[string]entry),
+	}
+}
+
+// Load returns the payload stored under the id along with its version.
+func (s *Store) Load(_ context.Context, id string) (plinko.Payload, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, 0, plinkoerror.CreatePlinkoNotFoundError(id, fmt.Sprintf("Payload '%s' not found in store", id))
+	}
+
+	return e.payload.(plinko.Cloneable).Clone(), e.version, nil
+}
+
+// Save stores the payload when the stored version matches the expected version, returning the
+// new version.
+func (s *Store) Save(_ context.Context, id string, payload plinko.Payload, expectedVersion int64) (int64, error) {
+	cloneable, ok := payload.(plinko.Cloneable)
+	if !ok {
+		return 0, fmt.Errorf("%w: payload '%s' is %T", ErrNotCloneable, id, payload)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.entries[id].version
+	if current != expectedVersion {
+		return current, plinkoerror.CreatePlinkoConflictError(id, expectedVersion, current, fmt.Sprintf("Payload '%s' is at version %d, expected version %d", id, current, expectedVersion))
+	}
+
+	s.entries[id] = entry{payload: cloneable.Clone(), version: current + 1}
+
+	return current + 1, nil
+}
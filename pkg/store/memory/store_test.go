@@ -0,0 +1,36 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) plinko.Store {
+		return NewStore()
+	})
+}
+
+type sharedPayload struct {
+	state plinko.State
+}
+
+func (p *sharedPayload) GetState() plinko.State {
+	return p.state
+}
+
+func TestSaveRequiresCloneable(t *testing.T) {
+	_, err := NewStore().Save(context.TODO(), "order-1", &sharedPayload{state: "Created"}, 0)
+	assert.True(t, errors.Is(err, ErrNotCloneable))
+}
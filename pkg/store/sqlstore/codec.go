@@ -0,0 +1,42 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package sqlstore
+
+import (
+	"encoding/json"
+
+	"github.com/shipt/plinko"
+)
+
+// Codec serializes payloads to and from the payload column of the state table.
+type Codec interface {
+	Marshal(plinko.Payload) ([]byte, error)
+	Unmarshal([]byte) (plinko.Payload, error)
+}
+
+type jsonCodec struct {
+	newPayload func() plinko.Payload
+}
+
+// JSONCodec serializes payloads as JSON.  newPayload returns a pointer to an empty payload that
+// a stored payload is decoded into.
+func JSONCodec(newPayload func() plinko.Payload) Codec {
+	return jsonCodec{newPayload: newPayload}
+}
+
+func (c jsonCodec) Marshal(payload plinko.Payload) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
+func (c jsonCodec) Unmarshal(data []byte) (plinko.Payload, error) {
+	payload := c.newPayload()
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,57 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package sqlstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect adapts the statements issued by the store to a particular database.
+type Dialect interface {
+	// Placeholder returns the bind parameter for the n-th argument of a statement, starting at 1.
+	Placeholder(n int) string
+
+	// InsertIfAbsent returns a statement inserting a row into the table that affects no rows,
+	// rather than failing, when a row with the same primary key already exists.
+	InsertIfAbsent(table string, columns []string) string
+}
+
+// Postgres is the dialect for PostgreSQL, and is used when no dialect is configured.
+var Postgres Dialect = postgres{}
+
+// MySQL is the dialect for MySQL and MariaDB.
+var MySQL Dialect = mysql{}
+
+type postgres struct{}
+
+func (postgres) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (d postgres) InsertIfAbsent(table string, columns []string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING", table, strings.Join(columns, ", "), placeholders(d, len(columns)))
+}
+
+type mysql struct{}
+
+func (mysql) Placeholder(int) string {
+	return "?"
+}
+
+func (d mysql) InsertIfAbsent(table string, columns []string) string {
+	return fmt.Sprintf("INSERT IGNORE INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders(d, len(columns)))
+}
+
+func placeholders(d Dialect, n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = d.Placeholder(i + 1)
+	}
+
+	return strings.Join(p, ", ")
+}
@@ -0,0 +1,229 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package sqlstore
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is an in-memory database.  It understands only the statements issued by Store with
+// the default table names, and records every statement so their shape can be checked for each
+// dialect.
+type fakeDB struct {
+	mu          sync.Mutex
+	statements  []string
+	rows        map[string]fakeRow
+	transitions [][]driver.Value
+	commits     int
+	rollbacks   int
+	failInserts bool
+}
+
+type fakeRow struct {
+	state   string
+	version int64
+	payload []byte
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{rows: map[string]fakeRow{}}
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: db}, nil
+}
+
+func (db *fakeDB) Driver() driver.Driver {
+	return fakeDriver{db: db}
+}
+
+type fakeDriver struct {
+	db *fakeDB
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{db: d.db}, nil
+}
+
+// fakeConn holds the changes of an open transaction until it is committed.
+type fakeConn struct {
+	db *fakeDB
+	tx *fakeTx
+}
+
+type fakeTx struct {
+	conn        *fakeConn
+	rows        map[string]fakeRow
+	transitions [][]driver.Value
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	rows := make(map[string]fakeRow, len(c.db.rows))
+	for k, v := range c.db.rows {
+		rows[k] = v
+	}
+
+	c.tx = &fakeTx{conn: c, rows: rows}
+
+	return c.tx, nil
+}
+
+func (tx *fakeTx) Commit() error {
+	db := tx.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.rows = tx.rows
+	db.transitions = append(db.transitions, tx.transitions...)
+	db.commits++
+	tx.conn.tx = nil
+
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	db := tx.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.rollbacks++
+	tx.conn.tx = nil
+
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rows := db.rows
+	if s.conn.tx != nil {
+		rows = s.conn.tx.rows
+	}
+
+	db.statements = append(db.statements, s.query)
+
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO plinko_states"), strings.HasPrefix(s.query, "INSERT IGNORE INTO plinko_states"):
+		if db.failInserts {
+			return nil, errors.New("insert failed")
+		}
+
+		id := args[0].(string)
+		if _, ok := rows[id]; ok {
+			return driver.RowsAffected(0), nil
+		}
+		rows[id] = fakeRow{state: args[1].(string), version: args[2].(int64), payload: args[3].([]byte)}
+
+		return driver.RowsAffected(1), nil
+
+	case strings.HasPrefix(s.query, "UPDATE plinko_states"):
+		id := args[3].(string)
+		if row, ok := rows[id]; !ok || row.version != args[4].(int64) {
+			return driver.RowsAffected(0), nil
+		}
+		rows[id] = fakeRow{state: args[0].(string), version: args[1].(int64), payload: args[2].([]byte)}
+
+		return driver.RowsAffected(1), nil
+
+	case strings.HasPrefix(s.query, "INSERT INTO plinko_transitions"):
+		if s.conn.tx != nil {
+			s.conn.tx.transitions = append(s.conn.tx.transitions, args)
+		} else {
+			db.transitions = append(db.transitions, args)
+		}
+
+		return driver.RowsAffected(1), nil
+	}
+
+	return nil, fmt.Errorf("unexpected statement: %s", s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rows := db.rows
+	if s.conn.tx != nil {
+		rows = s.conn.tx.rows
+	}
+
+	db.statements = append(db.statements, s.query)
+	row, ok := rows[args[0].(string)]
+
+	switch {
+	case strings.HasPrefix(s.query, "SELECT payload, version FROM plinko_states"):
+		if !ok {
+			return &fakeRows{columns: []string{"payload", "version"}}, nil
+		}
+		return &fakeRows{columns: []string{"payload", "version"}, values: [][]driver.Value{{row.payload, row.version}}}, nil
+
+	case strings.HasPrefix(s.query, "SELECT version FROM plinko_states"):
+		if !ok {
+			return &fakeRows{columns: []string{"version"}}, nil
+		}
+		return &fakeRows{columns: []string{"version"}, values: [][]driver.Value{{row.version}}}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected query: %s", s.query)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+
+	return nil
+}
@@ -0,0 +1,240 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package sqlstore implements plinko.Store on top of database/sql.
+//
+// The store keeps the current state, version and serialized payload of every entity in a state
+// table, and appends the transitions made by FireByID to a transition table within the same
+// transaction as the load, fire and save.  The transitions of a fire that failed are appended
+// once its transaction has been rolled back, with the error of the transition that failed.  The
+// expected schema, shown for PostgreSQL, is:
+//
+//	CREATE TABLE plinko_states (
+//	   id      TEXT PRIMARY KEY,
+//	   state   TEXT NOT NULL,
+//	   version BIGINT NOT NULL,
+//	   payload BYTEA NOT NULL
+//	);
+//
+//	CREATE TABLE plinko_transitions (
+//	   id           TEXT NOT NULL,
+//	   version      BIGINT NOT NULL,
+//	   source       TEXT NOT NULL,
+//	   destination  TEXT NOT NULL,
+//	   trigger_name TEXT NOT NULL,
+//	   error        TEXT,
+//	   recorded_at  TIMESTAMP NOT NULL
+//	);
+//
+// MySQL uses VARCHAR for the id, which it cannot index as TEXT, and BLOB for the payload.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+const (
+	DefaultStateTable      = "plinko_states"
+	DefaultTransitionTable = "plinko_transitions"
+)
+
+// Store is a plinko.Store backed by a database/sql database.  It implements
+// plinko.TransactionalStore and plinko.TransitionRecorder, so FireByID persists the payload
+// and its transitions atomically.
+type Store struct {
+	db              *sql.DB
+	codec           Codec
+	dialect         Dialect
+	stateTable      string
+	transitionTable string
+	now             func() time.Time
+}
+
+// Option configures a Store.
+type Option func(s *Store)
+
+// WithDialect sets the dialect used to build statements, which defaults to Postgres.
+func WithDialect(dialect Dialect) Option {
+	return func(s *Store) {
+		s.dialect = dialect
+	}
+}
+
+// WithStateTable overrides the name of the table holding the current state of each entity.
+func WithStateTable(table string) Option {
+	return func(s *Store) {
+		s.stateTable = table
+	}
+}
+
+// WithTransitionTable overrides the name of the table holding the transition history.
+func WithTransitionTable(table string) Option {
+	return func(s *Store) {
+		s.transitionTable = table
+	}
+}
+
+// New creates a store over the database, serializing payloads with the codec.
+func New(db *sql.DB, codec Codec, opts ...Option) *Store {
+	s := &Store{
+		db:              db,
+		codec:           codec,
+		dialect:         Postgres,
+		stateTable:      DefaultStateTable,
+		transitionTable: DefaultTransitionTable,
+		now:             time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type txKey struct{}
+
+// TxFrom returns the transaction FireByID is running in, so operations can make their own
+// changes within it.
+func TxFrom(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// WithinTransaction runs fn in a transaction that is committed when fn succeeds and rolled back
+// otherwise.  When the context already carries a transaction, fn joins it.
+func (s *Store) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TxFrom(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) queryer(ctx context.Context) queryer {
+	if tx, ok := TxFrom(ctx); ok {
+		return tx
+	}
+
+	return s.db
+}
+
+// Load reads the payload stored under the id along with its version.
+func (s *Store) Load(ctx context.Context, id string) (plinko.Payload, int64, error) {
+	query := fmt.Sprintf("SELECT payload, version FROM %s WHERE id = %s", s.stateTable, s.dialect.Placeholder(1))
+
+	var data []byte
+	var version int64
+	err := s.queryer(ctx).QueryRowContext(ctx, query, id).Scan(&data, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, plinkoerror.CreatePlinkoNotFoundError(id, fmt.Sprintf("Payload '%s' not found in store", id))
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	payload, err := s.codec.Unmarshal(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return payload, version, nil
+}
+
+// Save writes the payload when the stored version matches the expected version, returning the
+// new version.  An expected version of 0 inserts the payload.
+func (s *Store) Save(ctx context.Context, id string, payload plinko.Payload, expectedVersion int64) (int64, error) {
+	data, err := s.codec.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	q := s.queryer(ctx)
+	version := expectedVersion + 1
+
+	var result sql.Result
+	if expectedVersion == 0 {
+		query := s.dialect.InsertIfAbsent(s.stateTable, []string{"id", "state", "version", "payload"})
+		result, err = q.ExecContext(ctx, query, id, string(payload.GetState()), version, data)
+	} else {
+		query := fmt.Sprintf("UPDATE %s SET state = %s, version = %s, payload = %s WHERE id = %s AND version = %s",
+			s.stateTable, s.dialect.Placeholder(1), s.dialect.Placeholder(2), s.dialect.Placeholder(3), s.dialect.Placeholder(4), s.dialect.Placeholder(5))
+		result, err = q.ExecContext(ctx, query, string(payload.GetState()), version, data, id, expectedVersion)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if affected == 0 {
+		return s.conflict(ctx, q, id, expectedVersion)
+	}
+
+	return version, nil
+}
+
+func (s *Store) conflict(ctx context.Context, q queryer, id string, expectedVersion int64) (int64, error) {
+	query := fmt.Sprintf("SELECT version FROM %s WHERE id = %s", s.stateTable, s.dialect.Placeholder(1))
+
+	var current int64
+	if err := q.QueryRowContext(ctx, query, id).Scan(&current); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	return current, plinkoerror.CreatePlinkoConflictError(id, expectedVersion, current, fmt.Sprintf("Payload '%s' is at version %d, expected version %d", id, current, expectedVersion))
+}
+
+// RecordTransitions appends the transitions made by FireByID to the transition table, along
+// with the error of any transition that failed.
+func (s *Store) RecordTransitions(ctx context.Context, id string, version int64, results []plinko.TransitionResult) error {
+	query := fmt.Sprintf("INSERT INTO %s (id, version, source, destination, trigger_name, error, recorded_at) VALUES (%s)",
+		s.transitionTable, placeholders(s.dialect, 7))
+
+	q := s.queryer(ctx)
+	recordedAt := s.now().UTC()
+
+	for _, r := range results {
+		var errMessage sql.NullString
+		if r.Err != nil {
+			errMessage = sql.NullString{String: r.Err.Error(), Valid: true}
+		}
+
+		if _, err := q.ExecContext(ctx, query, id, version, string(r.Source), string(r.Destination), string(r.Trigger), errMessage, recordedAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/pkg/config"
+	"github.com/shipt/plinko/pkg/config/definition"
+	"github.com/shipt/plinko/pkg/store/storetest"
+	"github.com/shipt/plinko/plinkoerror"
+	"github.com/stretchr/testify/assert"
+)
+
+const Created plinko.State = "Created"
+const Opened plinko.State = "Opened"
+const Claimed plinko.State = "Claimed"
+const Canceled plinko.State = "Canceled"
+
+const Open plinko.Trigger = "Open"
+const Claim plinko.Trigger = "Claim"
+const Cancel plinko.Trigger = "Cancel"
+
+func newPayload() plinko.Payload {
+	return &storetest.Payload{}
+}
+
+func newStore(db *fakeDB, opts ...Option) *Store {
+	return New(sql.OpenDB(db), JSONCodec(newPayload), opts...)
+}
+
+func TestStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) plinko.Store {
+		return newStore(newFakeDB())
+	})
+}
+
+func TestStoreConformanceMySQL(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) plinko.Store {
+		return newStore(newFakeDB(), WithDialect(MySQL))
+	})
+}
+
+func setState(_ context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+	p.(*storetest.Payload).State = t.GetDestination()
+	return p, nil
+}
+
+func createStateMachine(store plinko.Store) plinko.StateMachine {
+	p := config.CreatePlinkoDefinition(definition.WithStore(store))
+
+	p.Configure(Created).
+		Permit(Open, Opened).
+		Permit(Cancel, Canceled)
+
+	p.Configure(Canceled).
+		OnEntry(func(_ context.Context, p plinko.Payload, _ plinko.TransitionInfo) (plinko.Payload, error) {
+			return p, errors.New("refund rejected")
+		})
+
+	p.Configure(Opened).
+		OnEntry(setState).
+		Permit(Claim, Claimed)
+
+	p.Configure(Claimed).
+		OnEntry(func(ctx context.Context, p plinko.Payload, t plinko.TransitionInfo) (plinko.Payload, error) {
+			if _, ok := TxFrom(ctx); !ok {
+				return p, errors.New("expected to run within the store transaction")
+			}
+			return setState(ctx, p, t)
+		})
+
+	return p.Compile().StateMachine
+}
+
+func TestFireByIDRecordsTransitions(t *testing.T) {
+	db := newFakeDB()
+	store := newStore(db)
+	sm := createStateMachine(store)
+
+	_, err := store.Save(context.TODO(), "order-1", &storetest.Payload{ID: "order-1", State: Created}, 0)
+	assert.Nil(t, err)
+
+	payload, err := sm.FireByID(context.TODO(), "order-1", Open)
+	assert.Nil(t, err)
+	assert.Equal(t, Opened, payload.GetState())
+
+	payload, err = sm.FireByID(context.TODO(), "order-1", Claim)
+	assert.Nil(t, err)
+	assert.Equal(t, Claimed, payload.GetState())
+
+	assert.Equal(t, 2, db.commits)
+	assert.Equal(t, fakeRow{state: "Claimed", version: 3, payload: db.rows["order-1"].payload}, db.rows["order-1"])
+
+	assert.Equal(t, 2, len(db.transitions))
+	assert.Equal(t, []driver.Value{"order-1", int64(2), "Created", "Opened", "Open", nil}, db.transitions[0][:6])
+	assert.Equal(t, []driver.Value{"order-1", int64(3), "Opened", "Claimed", "Claim", nil}, db.transitions[1][:6])
+}
+
+func TestFireByIDRollsBack(t *testing.T) {
+	db := newFakeDB()
+	store := newStore(db)
+	sm := createStateMachine(store)
+
+	_, err := store.Save(context.TODO(), "order-1", &storetest.Payload{ID: "order-1", State: Created}, 0)
+	assert.Nil(t, err)
+
+	_, err = sm.FireByID(context.TODO(), "order-1", Claim)
+	var pte *plinkoerror.PlinkoTriggerError
+	assert.True(t, errors.As(err, &pte))
+
+	assert.Equal(t, 0, db.commits)
+	assert.Equal(t, 1, db.rollbacks)
+	assert.Equal(t, int64(1), db.rows["order-1"].version)
+	assert.Equal(t, 0, len(db.transitions))
+}
+
+func TestFireByIDRecordsFailedTransitions(t *testing.T) {
+	db := newFakeDB()
+	store := newStore(db)
+	sm := createStateMachine(store)
+
+	_, err := store.Save(context.TODO(), "order-1", &storetest.Payload{ID: "order-1", State: Created}, 0)
+	assert.Nil(t, err)
+
+	_, err = sm.FireByID(context.TODO(), "order-1", Cancel)
+	assert.EqualError(t, err, "refund rejected")
+
+	assert.Equal(t, 0, db.commits)
+	assert.Equal(t, 1, db.rollbacks)
+	assert.Equal(t, int64(1), db.rows["order-1"].version)
+
+	assert.Equal(t, 1, len(db.transitions))
+	assert.Equal(t, []driver.Value{"order-1", int64(1), "Created", "Canceled", "Cancel", "refund rejected"}, db.transitions[0][:6])
+}
+
+func TestSaveError(t *testing.T) {
+	db := newFakeDB()
+	db.failInserts = true
+
+	_, err := newStore(db).Save(context.TODO(), "order-1", &storetest.Payload{ID: "order-1", State: Created}, 0)
+	assert.EqualError(t, err, "insert failed")
+}
+
+func TestDialects(t *testing.T) {
+	columns := []string{"id", "state"}
+
+	assert.Equal(t, "INSERT INTO states (id, state) VALUES ($1, $2) ON CONFLICT DO NOTHING", Postgres.InsertIfAbsent("states", columns))
+	assert.Equal(t, "INSERT IGNORE INTO states (id, state) VALUES (?, ?)", MySQL.InsertIfAbsent("states", columns))
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		dialect    Dialect
+		statements []string
+	}{
+		{Postgres, []string{
+			"INSERT INTO plinko_states (id, state, version, payload) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING",
+			"SELECT payload, version FROM plinko_states WHERE id = $1",
+			"UPDATE plinko_states SET state = $1, version = $2, payload = $3 WHERE id = $4 AND version = $5",
+			"INSERT INTO plinko_transitions (id, version, source, destination, trigger_name, error, recorded_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		}},
+		{MySQL, []string{
+			"INSERT IGNORE INTO plinko_states (id, state, version, payload) VALUES (?, ?, ?, ?)",
+			"SELECT payload, version FROM plinko_states WHERE id = ?",
+			"UPDATE plinko_states SET state = ?, version = ?, payload = ? WHERE id = ? AND version = ?",
+			"INSERT INTO plinko_transitions (id, version, source, destination, trigger_name, error, recorded_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		}},
+	}
+
+	for _, test := range tests {
+		db := newFakeDB()
+		store := newStore(db, WithDialect(test.dialect))
+		sm := createStateMachine(store)
+
+		_, err := store.Save(context.TODO(), "order-1", &storetest.Payload{ID: "order-1", State: Created}, 0)
+		assert.Nil(t, err)
+
+		_, err = sm.FireByID(context.TODO(), "order-1", Open)
+		assert.Nil(t, err)
+
+		assert.Equal(t, test.statements, db.statements)
+	}
+}
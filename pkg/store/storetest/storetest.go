@@ -0,0 +1,209 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package storetest provides a conformance suite for implementations of plinko.Store.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/shipt/plinko/plinkoerror"
+)
+
+// Payload is the payload saved and loaded by the conformance suite.  Stores that serialize
+// payloads must be able to round-trip it.
+type Payload struct {
+	ID    string
+	State plinko.State
+}
+
+func (p *Payload) GetState() plinko.State {
+	return p.State
+}
+
+func (p *Payload) Clone() plinko.Payload {
+	c := *p
+	return &c
+}
+
+// Run exercises the store returned by newStore against the plinko.Store contract.  Every test
+// receives a fresh store.
+func Run(t *testing.T, newStore func(t *testing.T) plinko.Store) {
+	t.Run("LoadMissing", func(t *testing.T) {
+		testLoadMissing(t, newStore(t))
+	})
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		testSaveAndLoad(t, newStore(t))
+	})
+	t.Run("SaveConflict", func(t *testing.T) {
+		testSaveConflict(t, newStore(t))
+	})
+	t.Run("CreateConflict", func(t *testing.T) {
+		testCreateConflict(t, newStore(t))
+	})
+	t.Run("ConcurrentSave", func(t *testing.T) {
+		testConcurrentSave(t, newStore(t))
+	})
+	t.Run("UnsavedChanges", func(t *testing.T) {
+		testUnsavedChanges(t, newStore(t))
+	})
+}
+
+func testLoadMissing(t *testing.T, store plinko.Store) {
+	_, _, err := store.Load(context.TODO(), "missing")
+
+	var nf *plinkoerror.PlinkoNotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("expected a PlinkoNotFoundError loading a missing payload, got %v", err)
+	}
+}
+
+func testSaveAndLoad(t *testing.T, store plinko.Store) {
+	ctx := context.TODO()
+
+	version, err := store.Save(ctx, "order-1", &Payload{ID: "order-1", State: "Created"}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error creating payload: %v", err)
+	}
+	if version != 1 {
+		t.Fatalf("expected version 1 after creating payload, got %d", version)
+	}
+
+	version, err = store.Save(ctx, "order-1", &Payload{ID: "order-1", State: "Opened"}, version)
+	if err != nil {
+		t.Fatalf("unexpected error updating payload: %v", err)
+	}
+	if version != 2 {
+		t.Fatalf("expected version 2 after updating payload, got %d", version)
+	}
+
+	payload, version, err := store.Load(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("unexpected error loading payload: %v", err)
+	}
+	if version != 2 {
+		t.Fatalf("expected to load version 2, got %d", version)
+	}
+	if payload.GetState() != "Opened" {
+		t.Fatalf("expected to load state Opened, got %s", payload.GetState())
+	}
+}
+
+func testSaveConflict(t *testing.T, store plinko.Store) {
+	ctx := context.TODO()
+
+	if _, err := store.Save(ctx, "order-1", &Payload{ID: "order-1", State: "Created"}, 0); err != nil {
+		t.Fatalf("unexpected error creating payload: %v", err)
+	}
+	if _, err := store.Save(ctx, "order-1", &Payload{ID: "order-1", State: "Opened"}, 1); err != nil {
+		t.Fatalf("unexpected error updating payload: %v", err)
+	}
+
+	_, err := store.Save(ctx, "order-1", &Payload{ID: "order-1", State: "Canceled"}, 1)
+
+	var ce *plinkoerror.PlinkoConflictError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a PlinkoConflictError saving a stale version, got %v", err)
+	}
+	if ce.ID != "order-1" || ce.ExpectedVersion != 1 {
+		t.Fatalf("expected the conflict to report order-1 at expected version 1, got %+v", ce)
+	}
+
+	payload, _, err := store.Load(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("unexpected error loading payload: %v", err)
+	}
+	if payload.GetState() != "Opened" {
+		t.Fatalf("a conflicting save must not change the payload, loaded state %s", payload.GetState())
+	}
+}
+
+func testCreateConflict(t *testing.T, store plinko.Store) {
+	ctx := context.TODO()
+
+	if _, err := store.Save(ctx, "order-1", &Payload{ID: "order-1", State: "Created"}, 0); err != nil {
+		t.Fatalf("unexpected error creating payload: %v", err)
+	}
+
+	_, err := store.Save(ctx, "order-1", &Payload{ID: "order-1", State: "Created"}, 0)
+
+	var ce *plinkoerror.PlinkoConflictError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a PlinkoConflictError creating an existing payload, got %v", err)
+	}
+}
+
+func testConcurrentSave(t *testing.T, store plinko.Store) {
+	const writers = 8
+	ctx := context.TODO()
+
+	if _, err := store.Save(ctx, "order-1", &Payload{ID: "order-1", State: "Created"}, 0); err != nil {
+		t.Fatalf("unexpected error creating payload: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := store.Save(ctx, "order-1", &Payload{ID: "order-1", State: plinko.State(fmt.Sprintf("State%d", i))}, 1)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		var ce *plinkoerror.PlinkoConflictError
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.As(err, &ce):
+			t.Fatalf("expected a PlinkoConflictError from a concurrent save, got %v", err)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Fatalf("expected exactly one concurrent save of the same version to succeed, %d did", succeeded)
+	}
+}
+
+func testUnsavedChanges(t *testing.T, store plinko.Store) {
+	ctx := context.TODO()
+
+	saved := &Payload{ID: "order-1", State: "Created"}
+	if _, err := store.Save(ctx, "order-1", saved, 0); err != nil {
+		t.Fatalf("unexpected error creating payload: %v", err)
+	}
+	saved.State = "Canceled"
+
+	payload, _, err := store.Load(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("unexpected error loading payload: %v", err)
+	}
+	if payload.GetState() != "Created" {
+		t.Fatalf("changing a payload after saving it must not change the stored payload, loaded state %s", payload.GetState())
+	}
+
+	// a fire that fails, or is never saved, changes the loaded payload only
+	payload.(*Payload).State = "Opened"
+
+	payload, _, err = store.Load(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("unexpected error loading payload: %v", err)
+	}
+	if payload.GetState() != "Created" {
+		t.Fatalf("changing a loaded payload without saving it must not change the stored payload, loaded state %s", payload.GetState())
+	}
+}
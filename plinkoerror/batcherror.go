@@ -0,0 +1,25 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+type PlinkoBatchError struct {
+	Failed       int
+	Total        int
+	ErrorMessage string
+}
+
+func (e *PlinkoBatchError) Error() string {
+	return e.ErrorMessage
+}
+
+func CreatePlinkoBatchError(failed, total int, errorMessage string) error {
+	return &PlinkoBatchError{
+		Failed:       failed,
+		Total:        total,
+		ErrorMessage: errorMessage,
+	}
+}
@@ -0,0 +1,27 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePlinkoBatchError(t *testing.T) {
+	var e *PlinkoBatchError
+	err := CreatePlinkoBatchError(2, 5, "set")
+
+	if errors.As(err, &e) {
+		assert.Equal(t, 2, e.Failed)
+		assert.Equal(t, 5, e.Total)
+		assert.Equal(t, "set", e.Error())
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+}
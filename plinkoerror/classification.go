@@ -0,0 +1,65 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import "errors"
+
+// PlinkoRetryableError marks a failure that may succeed if the trigger is fired again later.
+type PlinkoRetryableError struct {
+	Cause error
+}
+
+func (e *PlinkoRetryableError) Error() string {
+	return e.Cause.Error()
+}
+
+func (e *PlinkoRetryableError) Unwrap() error {
+	return e.Cause
+}
+
+// PlinkoFatalError marks a failure that will not succeed no matter how often the trigger is fired.
+type PlinkoFatalError struct {
+	Cause error
+}
+
+func (e *PlinkoFatalError) Error() string {
+	return e.Cause.Error()
+}
+
+func (e *PlinkoFatalError) Unwrap() error {
+	return e.Cause
+}
+
+// Retryable classifies the error as retryable.  It returns nil when err is nil.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PlinkoRetryableError{Cause: err}
+}
+
+// Fatal classifies the error as fatal.  It returns nil when err is nil.
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PlinkoFatalError{Cause: err}
+}
+
+// IsRetryable reports if the error, or an error it wraps, was classified as retryable.
+func IsRetryable(err error) bool {
+	var e *PlinkoRetryableError
+	return errors.As(err, &e)
+}
+
+// IsFatal reports if the error, or an error it wraps, was classified as fatal.
+func IsFatal(err error) bool {
+	var e *PlinkoFatalError
+	return errors.As(err, &e)
+}
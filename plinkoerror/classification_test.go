@@ -0,0 +1,42 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryable(t *testing.T) {
+	err := fmt.Errorf("charging card: %w", Retryable(context.DeadlineExceeded))
+
+	assert.Equal(t, "charging card: context deadline exceeded", err.Error())
+	assert.True(t, IsRetryable(err))
+	assert.False(t, IsFatal(err))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Nil(t, Retryable(nil))
+}
+
+func TestFatal(t *testing.T) {
+	var e *PlinkoFatalError
+	err := Fatal(errors.New("card declined"))
+
+	if errors.As(err, &e) {
+		assert.Equal(t, "card declined", e.Error())
+		assert.True(t, IsFatal(err))
+		assert.False(t, IsRetryable(err))
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+
+	assert.Nil(t, Fatal(nil))
+	assert.False(t, IsFatal(nil))
+}
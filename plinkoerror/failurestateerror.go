@@ -0,0 +1,34 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import "github.com/shipt/plinko"
+
+// PlinkoFailureStateError reports a transition that failed with a fatal error, after which the
+// payload was routed to the failure state of the definition.  The payload returned alongside it
+// is in the failure state.
+type PlinkoFailureStateError struct {
+	State        plinko.State
+	Cause        error
+	ErrorMessage string
+}
+
+func (e *PlinkoFailureStateError) Error() string {
+	return e.ErrorMessage
+}
+
+func (e *PlinkoFailureStateError) Unwrap() error {
+	return e.Cause
+}
+
+func CreatePlinkoFailureStateError(state plinko.State, cause error, errorMessage string) error {
+	return &PlinkoFailureStateError{
+		State:        state,
+		Cause:        cause,
+		ErrorMessage: errorMessage,
+	}
+}
@@ -0,0 +1,28 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePlinkoFailureStateError(t *testing.T) {
+	var e *PlinkoFailureStateError
+	cause := Fatal(errors.New("card declined"))
+	err := CreatePlinkoFailureStateError("Failed", cause, "set")
+
+	if errors.As(err, &e) {
+		assert.Equal(t, "Failed", string(e.State))
+		assert.Equal(t, "set", e.Error())
+		assert.True(t, IsFatal(err))
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+}
@@ -0,0 +1,33 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import "github.com/shipt/plinko"
+
+type PlinkoInvariantError struct {
+	State        plinko.State
+	Invariant    string
+	Cause        error
+	ErrorMessage string
+}
+
+func (e *PlinkoInvariantError) Error() string {
+	return e.ErrorMessage
+}
+
+func (e *PlinkoInvariantError) Unwrap() error {
+	return e.Cause
+}
+
+func CreatePlinkoInvariantError(state plinko.State, invariant string, cause error, errorMessage string) error {
+	return &PlinkoInvariantError{
+		State:        state,
+		Invariant:    invariant,
+		Cause:        cause,
+		ErrorMessage: errorMessage,
+	}
+}
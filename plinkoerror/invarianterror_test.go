@@ -0,0 +1,30 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePlinkoInvariantError(t *testing.T) {
+	var e *PlinkoInvariantError
+	cause := errors.New("no shopper")
+	err := CreatePlinkoInvariantError("foo", "HasShopper", cause, "set")
+
+	if errors.As(err, &e) {
+		assert.Equal(t, plinko.State("foo"), e.State)
+		assert.Equal(t, "HasShopper", e.Invariant)
+		assert.Equal(t, "set", e.Error())
+		assert.True(t, errors.Is(err, cause))
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+}
@@ -0,0 +1,29 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+type PlinkoLockError struct {
+	ID           string
+	Cause        error
+	ErrorMessage string
+}
+
+func (e *PlinkoLockError) Error() string {
+	return e.ErrorMessage
+}
+
+func (e *PlinkoLockError) Unwrap() error {
+	return e.Cause
+}
+
+func CreatePlinkoLockError(id string, cause error, errorMessage string) error {
+	return &PlinkoLockError{
+		ID:           id,
+		Cause:        cause,
+		ErrorMessage: errorMessage,
+	}
+}
@@ -0,0 +1,28 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePlinkoLockError(t *testing.T) {
+	var e *PlinkoLockError
+	err := CreatePlinkoLockError("foo", context.DeadlineExceeded, "set")
+
+	if errors.As(err, &e) {
+		assert.Equal(t, "foo", e.ID)
+		assert.Equal(t, "set", e.Error())
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+}
@@ -0,0 +1,27 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import "github.com/shipt/plinko"
+
+type PlinkoQueueDepthError struct {
+	plinko.Trigger
+	Depth        int
+	ErrorMessage string
+}
+
+func (e *PlinkoQueueDepthError) Error() string {
+	return e.ErrorMessage
+}
+
+func CreatePlinkoQueueDepthError(trigger plinko.Trigger, depth int, errorMessage string) error {
+	return &PlinkoQueueDepthError{
+		Trigger:      trigger,
+		Depth:        depth,
+		ErrorMessage: errorMessage,
+	}
+}
@@ -0,0 +1,28 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shipt/plinko"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePlinkoQueueDepthError(t *testing.T) {
+	var e *PlinkoQueueDepthError
+	err := CreatePlinkoQueueDepthError("foo", 3, "set")
+
+	if errors.As(err, &e) {
+		assert.Equal(t, plinko.Trigger("foo"), e.Trigger)
+		assert.Equal(t, 3, e.Depth)
+		assert.Equal(t, "set", e.Error())
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+}
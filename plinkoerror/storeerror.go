@@ -0,0 +1,43 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+type PlinkoConflictError struct {
+	ID              string
+	ExpectedVersion int64
+	ActualVersion   int64
+	ErrorMessage    string
+}
+
+func (e *PlinkoConflictError) Error() string {
+	return e.ErrorMessage
+}
+
+func CreatePlinkoConflictError(id string, expectedVersion, actualVersion int64, errorMessage string) error {
+	return &PlinkoConflictError{
+		ID:              id,
+		ExpectedVersion: expectedVersion,
+		ActualVersion:   actualVersion,
+		ErrorMessage:    errorMessage,
+	}
+}
+
+type PlinkoNotFoundError struct {
+	ID           string
+	ErrorMessage string
+}
+
+func (e *PlinkoNotFoundError) Error() string {
+	return e.ErrorMessage
+}
+
+func CreatePlinkoNotFoundError(id string, errorMessage string) error {
+	return &PlinkoNotFoundError{
+		ID:           id,
+		ErrorMessage: errorMessage,
+	}
+}
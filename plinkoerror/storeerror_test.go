@@ -0,0 +1,40 @@
+/**
+ * Copyright (c) Shipt.
+ *
+ * This source code is licensed under the MIT license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+package plinkoerror
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePlinkoConflictError(t *testing.T) {
+	var e *PlinkoConflictError
+	err := CreatePlinkoConflictError("foo", 1, 2, "set")
+
+	if errors.As(err, &e) {
+		assert.Equal(t, "foo", e.ID)
+		assert.Equal(t, int64(1), e.ExpectedVersion)
+		assert.Equal(t, int64(2), e.ActualVersion)
+		assert.Equal(t, "set", e.Error())
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+}
+
+func TestCreatePlinkoNotFoundError(t *testing.T) {
+	var e *PlinkoNotFoundError
+	err := CreatePlinkoNotFoundError("foo", "set")
+
+	if errors.As(err, &e) {
+		assert.Equal(t, "foo", e.ID)
+		assert.Equal(t, "set", e.Error())
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+}
@@ -10,6 +10,8 @@ import "github.com/shipt/plinko"
 
 type PlinkoTriggerError struct {
 	plinko.Trigger
+	// Cause is the error of the guard that rejected the trigger, if any.
+	Cause        error
 	ErrorMessage string
 }
 
@@ -17,9 +19,21 @@ func (e *PlinkoTriggerError) Error() string {
 	return e.ErrorMessage
 }
 
+func (e *PlinkoTriggerError) Unwrap() error {
+	return e.Cause
+}
+
 func CreatePlinkoTriggerError(trigger plinko.Trigger, errorMessage string) error {
 	return &PlinkoTriggerError{
 		Trigger:      trigger,
 		ErrorMessage: errorMessage,
 	}
 }
+
+func CreatePlinkoGuardError(trigger plinko.Trigger, cause error, errorMessage string) error {
+	return &PlinkoTriggerError{
+		Trigger:      trigger,
+		Cause:        cause,
+		ErrorMessage: errorMessage,
+	}
+}
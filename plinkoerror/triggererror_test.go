@@ -25,3 +25,17 @@ func TestCreatePlinkoTriggerError(t *testing.T) {
 		assert.Fail(t, "error not returning properly")
 	}
 }
+
+func TestCreatePlinkoGuardError(t *testing.T) {
+	var e *PlinkoTriggerError
+	cause := errors.New("guard failed")
+	err := CreatePlinkoGuardError("foo", cause, "set")
+
+	if errors.As(err, &e) {
+		assert.Equal(t, plinko.Trigger("foo"), e.Trigger)
+		assert.Equal(t, "set", e.Error())
+		assert.True(t, errors.Is(err, cause))
+	} else {
+		assert.Fail(t, "error not returning properly")
+	}
+}